@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// AlgorithmsModel restricts the algorithms negotiated with the server.
+type AlgorithmsModel struct {
+	Preset            types.String `tfsdk:"preset"`
+	Ciphers           types.List   `tfsdk:"ciphers"`
+	KeyExchanges      types.List   `tfsdk:"key_exchanges"`
+	MACs              types.List   `tfsdk:"macs"`
+	HostKeyAlgorithms types.List   `tfsdk:"host_key_algorithms"`
+}
+
+func algorithmsBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "Ciphers, key exchanges, MACs and host key algorithms offered to the server, in order of preference. Applies to jump hosts as well. Unset lists keep the defaults of the preset, or of the SSH library when no preset is set.",
+		Attributes: map[string]schema.Attribute{
+			"preset": schema.StringAttribute{
+				MarkdownDescription: "Named algorithm set: `fips` for FIPS 140-2 approved algorithms, `modern` for the defaults of current OpenSSH releases, or `legacy` to also allow CBC ciphers, SHA-1 key exchanges and `ssh-rsa`/`ssh-dss` host keys.",
+				Optional:            true,
+			},
+			"ciphers": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"key_exchanges": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"macs": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"host_key_algorithms": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+		},
+	}
+}
+
+func (m *AlgorithmsModel) algorithms(ctx context.Context) (remote.Algorithms, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var algs remote.Algorithms
+	if m == nil {
+		return algs, diags
+	}
+
+	diags.Append(m.Ciphers.ElementsAs(ctx, &algs.Ciphers, false)...)
+	diags.Append(m.KeyExchanges.ElementsAs(ctx, &algs.KeyExchanges, false)...)
+	diags.Append(m.MACs.ElementsAs(ctx, &algs.MACs, false)...)
+	diags.Append(m.HostKeyAlgorithms.ElementsAs(ctx, &algs.HostKeyAlgorithms, false)...)
+	if diags.HasError() {
+		return algs, diags
+	}
+
+	if !m.Preset.IsNull() {
+		preset, err := algs.Preset(m.Preset.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("algorithms").AtName("preset"), "Invalid Algorithm Preset", err.Error())
+			return algs, diags
+		}
+		algs = preset
+	}
+
+	if err := algs.Validate(); err != nil {
+		diags.AddAttributeError(path.Root("algorithms"), "Unsupported Algorithms", err.Error())
+	}
+	return algs, diags
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultCertificateExtensions matches the extensions ssh-keygen grants by default.
+var defaultCertificateExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// CertificateAuthorityModel describes the CA used to sign ephemeral user certificates.
+type CertificateAuthorityModel struct {
+	PrivateKey           types.String `tfsdk:"private_key"`
+	PrivateKeyPassphrase types.String `tfsdk:"private_key_passphrase"`
+	KeyID                types.String `tfsdk:"key_id"`
+	Principals           types.List   `tfsdk:"principals"`
+	TTL                  types.String `tfsdk:"ttl"`
+	Extensions           types.Map    `tfsdk:"extensions"`
+	CriticalOptions      types.Map    `tfsdk:"critical_options"`
+}
+
+func certificateAuthorityBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "Certificate authority used to sign a short-lived certificate for an in-memory key pair generated when the provider is configured.",
+		Attributes: map[string]schema.Attribute{
+			"private_key": schema.StringAttribute{
+				MarkdownDescription: "Private key of the certificate authority.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"private_key_passphrase": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"key_id": schema.StringAttribute{
+				MarkdownDescription: "Key ID recorded in the certificate. Defaults to `terraform-provider-ssh`.",
+				Optional:            true,
+			},
+			"principals": schema.ListAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Principals the certificate is valid for. Defaults to the provider `user`.",
+				Optional:            true,
+			},
+			"ttl": schema.StringAttribute{
+				MarkdownDescription: "Lifetime of the certificate. A new certificate for the same key is signed once less than half of it remains. Defaults to `5m`.",
+				Optional:            true,
+			},
+			"extensions": schema.MapAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Certificate extensions. Defaults to the `ssh-keygen` set of `permit-X11-forwarding`, `permit-agent-forwarding`, `permit-port-forwarding`, `permit-pty` and `permit-user-rc`.",
+				Optional:            true,
+			},
+			"critical_options": schema.MapAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Certificate critical options, e.g. `force-command` or `source-address`.",
+				Optional:            true,
+			},
+		},
+	}
+}
+
+// signer generates an ephemeral key pair and signs a certificate for user,
+// renewed when it gets close to expiry.
+func (m *CertificateAuthorityModel) signer(ctx context.Context, user string) (ssh.Signer, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	root := path.Root("certificate_authority")
+
+	ca, err := remote.ParsePrivateKey([]byte(m.PrivateKey.ValueString()), m.PrivateKeyPassphrase.ValueString())
+	if err != nil {
+		diags.AddAttributeError(root.AtName("private_key"), "Invalid Certificate Authority Key", "The provider cannot parse the certificate authority key: "+err.Error())
+		return nil, diags
+	}
+
+	req := remote.CertificateRequest{
+		KeyID:      "terraform-provider-ssh",
+		Principals: []string{user},
+		TTL:        5 * time.Minute,
+		Extensions: defaultCertificateExtensions,
+	}
+
+	if !m.KeyID.IsNull() {
+		req.KeyID = m.KeyID.ValueString()
+	}
+	if !m.Principals.IsNull() {
+		diags.Append(m.Principals.ElementsAs(ctx, &req.Principals, false)...)
+	}
+	if !m.TTL.IsNull() {
+		if req.TTL, err = time.ParseDuration(m.TTL.ValueString()); err != nil {
+			diags.AddAttributeError(root.AtName("ttl"), "Invalid Certificate TTL", err.Error())
+		}
+	}
+	if !m.Extensions.IsNull() {
+		diags.Append(m.Extensions.ElementsAs(ctx, &req.Extensions, false)...)
+	}
+	if !m.CriticalOptions.IsNull() {
+		diags.Append(m.CriticalOptions.ElementsAs(ctx, &req.CriticalOptions, false)...)
+	}
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	signer, err := remote.NewCertificateSigner(ca, req)
+	if err != nil {
+		diags.AddAttributeError(root, "Certificate Signing Failed", err.Error())
+		return nil, diags
+	}
+	return signer, diags
+}
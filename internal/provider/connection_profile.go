@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"context"
+	"os"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ConnectionProfileModel describes a named connection that resources select
+// with connection_profile. Unset values default to the provider configuration.
+type ConnectionProfileModel struct {
+	Name                 types.String `tfsdk:"name"`
+	Host                 types.String `tfsdk:"host"`
+	Port                 types.String `tfsdk:"port"`
+	User                 types.String `tfsdk:"user"`
+	Password             types.String `tfsdk:"password"`
+	PrivateKey           types.String `tfsdk:"private_key"`
+	PrivateKeyFile       types.String `tfsdk:"private_key_file"`
+	PrivateKeyPassphrase types.String `tfsdk:"private_key_passphrase"`
+	Agent                types.Bool   `tfsdk:"agent"`
+	AgentSocket          types.String `tfsdk:"agent_socket"`
+	AgentIdentity        types.String `tfsdk:"agent_identity"`
+
+	KnownHosts          types.String `tfsdk:"known_hosts"`
+	KnownHostsFiles     types.List   `tfsdk:"known_hosts_files"`
+	HostKeyFingerprints types.List   `tfsdk:"host_key_fingerprints"`
+
+	JumpHosts    []JumpHostModel `tfsdk:"jump_host"`
+	Proxy        *ProxyModel     `tfsdk:"proxy"`
+	ProxyCommand types.String    `tfsdk:"proxy_command"`
+}
+
+func connectionProfileBlock() schema.ListNestedBlock {
+	return schema.ListNestedBlock{
+		MarkdownDescription: "Named connection profiles selected by resources with `connection_profile`. Settings not set in a profile default to the provider configuration, including its `jump_host` and `proxy` blocks.",
+		NestedObject: schema.NestedBlockObject{
+			Attributes: map[string]schema.Attribute{
+				"name": schema.StringAttribute{
+					MarkdownDescription: "Name of the profile, unique within the provider.",
+					Required:            true,
+				},
+				"host": schema.StringAttribute{
+					Required: true,
+				},
+				"port": schema.StringAttribute{
+					Optional: true,
+				},
+				"user": schema.StringAttribute{
+					Optional: true,
+				},
+				"password": schema.StringAttribute{
+					Optional:  true,
+					Sensitive: true,
+				},
+				"private_key": schema.StringAttribute{
+					Optional:  true,
+					Sensitive: true,
+				},
+				"private_key_file": schema.StringAttribute{
+					MarkdownDescription: "Path to the private key file. A leading `~` is expanded. Conflicts with `private_key`.",
+					Optional:            true,
+				},
+				"private_key_passphrase": schema.StringAttribute{
+					Optional:  true,
+					Sensitive: true,
+				},
+				"agent": schema.BoolAttribute{
+					Optional: true,
+				},
+				"agent_socket": schema.StringAttribute{
+					Optional: true,
+				},
+				"agent_identity": schema.StringAttribute{
+					Optional: true,
+				},
+				"known_hosts": schema.StringAttribute{
+					Optional: true,
+				},
+				"known_hosts_files": schema.ListAttribute{
+					ElementType: types.StringType,
+					Optional:    true,
+				},
+				"host_key_fingerprints": schema.ListAttribute{
+					ElementType: types.StringType,
+					Optional:    true,
+				},
+				"proxy_command": schema.StringAttribute{
+					Optional: true,
+				},
+			},
+			Blocks: map[string]schema.Block{
+				"jump_host": jumpHostBlock(),
+				"proxy":     proxyBlock(),
+			},
+		},
+	}
+}
+
+// config merges the profile over the provider defaults.
+func (m ConnectionProfileModel) config(ctx context.Context, defaults *remote.Config, root path.Path) (*remote.Config, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	c := defaults.Clone()
+	c.Host = m.Host.ValueString()
+	c.Port = m.Port.ValueString()
+
+	if !m.User.IsNull() {
+		c.User = m.User.ValueString()
+	}
+	if !m.Password.IsNull() {
+		c.Password = m.Password.ValueString()
+	}
+	if !m.PrivateKeyPassphrase.IsNull() {
+		c.Passphrase = m.PrivateKeyPassphrase.ValueString()
+	}
+
+	if !m.PrivateKey.IsNull() && !m.PrivateKeyFile.IsNull() {
+		diags.AddAttributeError(root.AtName("private_key_file"), "Conflicting Private Key", "Only one of private_key and private_key_file can be set.")
+		return nil, diags
+	}
+	if !m.PrivateKey.IsNull() || !m.PrivateKeyFile.IsNull() {
+		key := []byte(m.PrivateKey.ValueString())
+		if !m.PrivateKeyFile.IsNull() {
+			var err error
+			if key, err = os.ReadFile(remote.ExpandPath(m.PrivateKeyFile.ValueString())); err != nil {
+				diags.AddAttributeError(root.AtName("private_key_file"), "Unreadable Private Key File", "The provider cannot read the private key file: "+err.Error())
+				return nil, diags
+			}
+		}
+		if _, err := remote.ParsePrivateKey(key, c.Passphrase); err != nil {
+			diags.AddAttributeError(root.AtName("private_key"), "Invalid Private Key", "The provider cannot parse the private key: "+err.Error())
+			return nil, diags
+		}
+		c.PrivateKey = string(key)
+		// The provider certificate certifies the provider key, not this one.
+		c.Certificate = ""
+	}
+
+	if !m.Agent.IsNull() {
+		c.Agent = m.Agent.ValueBool()
+	}
+	if !m.AgentSocket.IsNull() {
+		c.AgentSocket = m.AgentSocket.ValueString()
+	}
+	if !m.AgentIdentity.IsNull() {
+		c.AgentIdentity = m.AgentIdentity.ValueString()
+	}
+
+	if !m.KnownHosts.IsNull() || !m.KnownHostsFiles.IsNull() || !m.HostKeyFingerprints.IsNull() {
+		hostKeys, d := hostKeyConfig(ctx, m.KnownHosts, m.KnownHostsFiles, m.HostKeyFingerprints)
+		diags.Append(d...)
+		c.HostKeys = hostKeys
+	}
+
+	if len(m.JumpHosts) > 0 {
+		c.JumpHosts = make([]*remote.Config, 0, len(m.JumpHosts))
+		for _, j := range m.JumpHosts {
+			jumpHost, d := j.config(ctx, c.User, c.HostKeys)
+			diags.Append(d...)
+			jumpHost.Timeout = c.Timeout
+			jumpHost.Algorithms = c.Algorithms
+			c.JumpHosts = append(c.JumpHosts, jumpHost)
+		}
+	}
+
+	if m.Proxy != nil {
+		proxy, d := m.Proxy.config(ctx)
+		diags.Append(d...)
+		c.Proxy = proxy
+	}
+	if !m.ProxyCommand.IsNull() {
+		c.ProxyCommand = m.ProxyCommand.ValueString()
+	}
+
+	return c, diags
+}
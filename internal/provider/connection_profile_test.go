@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// testPrivateKey returns a PKCS#8 encoded ed25519 private key.
+func testPrivateKey(t *testing.T) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// testDefaults returns provider settings for profiles to be merged over.
+func testDefaults() *remote.Config {
+	return &remote.Config{
+		Host:        "provider.example.com",
+		Port:        "2222",
+		User:        "deploy",
+		Password:    "secret",
+		Certificate: "ssh-ed25519-cert-v01@openssh.com AAAA",
+		Timeout:     20 * time.Second,
+		Agent:       true,
+	}
+}
+
+func TestConnectionProfileModel_config(t *testing.T) {
+	key := testPrivateKey(t)
+
+	tests := []struct {
+		name      string
+		profile   ConnectionProfileModel
+		want      func(c *remote.Config) bool
+		wantError string
+	}{
+		{
+			name:    "unset values default to the provider",
+			profile: ConnectionProfileModel{Name: types.StringValue("db"), Host: types.StringValue("db.example.com")},
+			want: func(c *remote.Config) bool {
+				return c.Host == "db.example.com" && c.User == "deploy" && c.Password == "secret" && c.Agent && c.Certificate != ""
+			},
+		},
+		{
+			name:    "port of the provider host is not inherited",
+			profile: ConnectionProfileModel{Name: types.StringValue("db"), Host: types.StringValue("db.example.com")},
+			want:    func(c *remote.Config) bool { return c.Port == "" },
+		},
+		{
+			name: "profile values win",
+			profile: ConnectionProfileModel{
+				Name:  types.StringValue("db"),
+				Host:  types.StringValue("db.example.com"),
+				Port:  types.StringValue("22"),
+				User:  types.StringValue("postgres"),
+				Agent: types.BoolValue(false),
+			},
+			want: func(c *remote.Config) bool { return c.Port == "22" && c.User == "postgres" && !c.Agent },
+		},
+		{
+			name:    "private key clears the provider certificate",
+			profile: ConnectionProfileModel{Name: types.StringValue("db"), Host: types.StringValue("db.example.com"), PrivateKey: types.StringValue(key)},
+			want:    func(c *remote.Config) bool { return c.PrivateKey == key && c.Certificate == "" },
+		},
+		{
+			name: "jump hosts default to the profile user",
+			profile: ConnectionProfileModel{
+				Name: types.StringValue("db"),
+				Host: types.StringValue("db.example.com"),
+				User: types.StringValue("postgres"),
+				JumpHosts: []JumpHostModel{{
+					Host:                types.StringValue("bastion.example.com"),
+					KnownHostsFiles:     types.ListNull(types.StringType),
+					HostKeyFingerprints: types.ListNull(types.StringType),
+				}},
+			},
+			want: func(c *remote.Config) bool {
+				return len(c.JumpHosts) == 1 && c.JumpHosts[0].User == "postgres" && c.JumpHosts[0].Timeout == 20*time.Second
+			},
+		},
+		{
+			name: "conflicting private keys",
+			profile: ConnectionProfileModel{
+				Name:           types.StringValue("db"),
+				Host:           types.StringValue("db.example.com"),
+				PrivateKey:     types.StringValue(key),
+				PrivateKeyFile: types.StringValue("~/.ssh/id_ed25519"),
+			},
+			wantError: "Conflicting Private Key",
+		},
+		{
+			name:      "invalid private key",
+			profile:   ConnectionProfileModel{Name: types.StringValue("db"), Host: types.StringValue("db.example.com"), PrivateKey: types.StringValue("not a key")},
+			wantError: "Invalid Private Key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := testDefaults()
+			got, diags := tt.profile.config(context.Background(), defaults, path.Root("connection").AtListIndex(0))
+			if tt.wantError != "" {
+				if !diags.HasError() || diags.Errors()[0].Summary() != tt.wantError {
+					t.Fatalf("config() diagnostics = %v, want %q", diags, tt.wantError)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("config() diagnostics = %v", diags)
+			}
+			if !tt.want(got) {
+				t.Errorf("config() = %+v", got)
+			}
+			if defaults.Host != "provider.example.com" || defaults.Certificate == "" {
+				t.Errorf("config() modified the provider defaults: %+v", defaults)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// environmentNamePattern matches the variable names a POSIX shell can
+// export, which the fallback for servers refusing env requests relies on.
+var environmentNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// environment converts an environment map attribute, checking its names.
+func environment(ctx context.Context, m types.Map, attr path.Path) (map[string]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if m.IsNull() || m.IsUnknown() {
+		return nil, diags
+	}
+
+	env := map[string]string{}
+	diags.Append(m.ElementsAs(ctx, &env, false)...)
+	for name := range env {
+		if !environmentNamePattern.MatchString(name) {
+			diags.AddAttributeError(attr, "Invalid Environment Variable",
+				fmt.Sprintf("The name %q is not a valid environment variable name. Names must consist of letters, digits and underscores and not start with a digit.", name))
+		}
+	}
+	return env, diags
+}
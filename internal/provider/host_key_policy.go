@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	hostKeyPolicyStrict = "strict"
+	hostKeyPolicyTOFU   = "tofu"
+)
+
+// hostKeyFingerprintModifier keeps the recorded host key fingerprint in the
+// plan unless host_key_rotation changes, in which case the fingerprint is
+// only known after the host key is trusted again.
+type hostKeyFingerprintModifier struct{}
+
+func (m hostKeyFingerprintModifier) Description(_ context.Context) string {
+	return "Keeps the recorded host key fingerprint unless host_key_rotation changes."
+}
+
+func (m hostKeyFingerprintModifier) MarkdownDescription(ctx context.Context) string {
+	return m.Description(ctx)
+}
+
+func (m hostKeyFingerprintModifier) PlanModifyString(ctx context.Context, req planmodifier.StringRequest, resp *planmodifier.StringResponse) {
+	if req.StateValue.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var planned, prior types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("host_key_rotation"), &planned)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("host_key_rotation"), &prior)...)
+	if planned.Equal(prior) {
+		resp.PlanValue = req.StateValue
+	}
+}
+
+// requireHostKey reports an error when host_key_policy is "tofu" but no host
+// key fingerprint is recorded, for operations that must not trust whatever
+// key the host presents now.
+func (d *providerData) requireHostKey(recorded types.String, action string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if d.tofu && recorded.ValueString() == "" {
+		diags.AddAttributeError(path.Root("host_key_fingerprint"), "Missing Host Key Fingerprint", fmt.Sprintf(
+			"Unable to %s, no host key fingerprint is recorded to verify the host against while the provider host_key_policy is %q. "+
+				"Change host_key_rotation and apply to record the host key first.", action, hostKeyPolicyTOFU))
+	}
+	return diags
+}
@@ -0,0 +1,203 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+const testFingerprint = "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+
+// hostKeySchema holds the attributes read by hostKeyFingerprintModifier.
+var hostKeySchema = schema.Schema{
+	Attributes: map[string]schema.Attribute{
+		"host_key_fingerprint": schema.StringAttribute{Computed: true},
+		"host_key_rotation":    schema.StringAttribute{Optional: true},
+	},
+}
+
+// hostKeyValue returns an object of hostKeySchema. An empty rotation is null.
+func hostKeyValue(fingerprint tftypes.Value, rotation string) tftypes.Value {
+	r := tftypes.NewValue(tftypes.String, nil)
+	if rotation != "" {
+		r = tftypes.NewValue(tftypes.String, rotation)
+	}
+	return tftypes.NewValue(hostKeySchema.Type().TerraformType(context.Background()), map[string]tftypes.Value{
+		"host_key_fingerprint": fingerprint,
+		"host_key_rotation":    r,
+	})
+}
+
+func TestHostKeyFingerprintModifier(t *testing.T) {
+	null := tftypes.NewValue(tftypes.String, nil)
+	unknown := tftypes.NewValue(tftypes.String, tftypes.UnknownValue)
+	recorded := tftypes.NewValue(tftypes.String, testFingerprint)
+	absent := tftypes.NewValue(hostKeySchema.Type().TerraformType(context.Background()), nil)
+
+	tests := []struct {
+		name  string
+		state tftypes.Value
+		plan  tftypes.Value
+		want  types.String
+	}{
+		{
+			name:  "create",
+			state: absent,
+			plan:  hostKeyValue(unknown, ""),
+			want:  types.StringUnknown(),
+		},
+		{
+			name:  "rotation unchanged",
+			state: hostKeyValue(recorded, "1"),
+			plan:  hostKeyValue(unknown, "1"),
+			want:  types.StringValue(testFingerprint),
+		},
+		{
+			name:  "rotation unset",
+			state: hostKeyValue(recorded, ""),
+			plan:  hostKeyValue(unknown, ""),
+			want:  types.StringValue(testFingerprint),
+		},
+		{
+			name:  "rotation changed",
+			state: hostKeyValue(recorded, "1"),
+			plan:  hostKeyValue(unknown, "2"),
+			want:  types.StringUnknown(),
+		},
+		{
+			name:  "rotation set",
+			state: hostKeyValue(recorded, ""),
+			plan:  hostKeyValue(unknown, "1"),
+			want:  types.StringUnknown(),
+		},
+		{
+			name:  "nothing recorded",
+			state: hostKeyValue(null, "1"),
+			plan:  hostKeyValue(unknown, "1"),
+			want:  types.StringUnknown(),
+		},
+		{
+			name:  "destroy",
+			state: hostKeyValue(recorded, "1"),
+			plan:  absent,
+			want:  types.StringUnknown(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			state := tfsdk.State{Schema: hostKeySchema, Raw: tt.state}
+			plan := tfsdk.Plan{Schema: hostKeySchema, Raw: tt.plan}
+
+			stateValue := types.StringNull()
+			if !tt.state.IsNull() {
+				if diags := state.GetAttribute(ctx, path.Root("host_key_fingerprint"), &stateValue); diags.HasError() {
+					t.Fatal(diags)
+				}
+			}
+
+			req := planmodifier.StringRequest{
+				Path:       path.Root("host_key_fingerprint"),
+				Plan:       plan,
+				PlanValue:  types.StringUnknown(),
+				State:      state,
+				StateValue: stateValue,
+			}
+			resp := &planmodifier.StringResponse{PlanValue: req.PlanValue}
+			hostKeyFingerprintModifier{}.PlanModifyString(ctx, req, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("PlanModifyString() diagnostics = %v", resp.Diagnostics)
+			}
+			if !resp.PlanValue.Equal(tt.want) {
+				t.Errorf("PlanModifyString() = %v, want %v", resp.PlanValue, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptResource_pinHostKey(t *testing.T) {
+	// A closed port, so that scanning the host key fails without a server.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	tests := []struct {
+		name      string
+		tofu      bool
+		recorded  types.String
+		rotate    bool
+		want      types.String
+		wantPin   bool
+		wantError bool
+	}{
+		{name: "strict", recorded: types.StringValue(testFingerprint), want: types.StringNull()},
+		{name: "strict rotation", recorded: types.StringNull(), rotate: true, want: types.StringNull()},
+		{name: "recorded", tofu: true, recorded: types.StringValue(testFingerprint), want: types.StringValue(testFingerprint), wantPin: true},
+		{name: "rotation scans the host", tofu: true, recorded: types.StringValue(testFingerprint), rotate: true, want: types.StringValue(testFingerprint), wantError: true},
+		{name: "nothing recorded scans the host", tofu: true, recorded: types.StringNull(), want: types.StringNull(), wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ScriptResource{provider: &providerData{tofu: tt.tofu}}
+			client := remote.NewProvisioner(&remote.Config{Host: host, Port: port, User: "deploy", Timeout: time.Second}, time.Minute, 0)
+
+			got, fingerprint, err := r.pinHostKey(context.Background(), client, tt.recorded, tt.rotate)
+			if !fingerprint.Equal(tt.want) {
+				t.Errorf("pinHostKey() fingerprint = %v, want %v", fingerprint, tt.want)
+			}
+			if tt.wantError {
+				if err == nil {
+					t.Fatal("pinHostKey() did not scan the host key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pinHostKey() error = %v", err)
+			}
+			if !tt.wantPin {
+				if got != client {
+					t.Errorf("pinHostKey() = %+v, want the unpinned provisioner", got.Ssh)
+				}
+				return
+			}
+			if fingerprints := got.Ssh.HostKeys.Fingerprints; len(fingerprints) != 1 || fingerprints[0] != testFingerprint {
+				t.Errorf("pinHostKey() pinned %v, want %s", fingerprints, testFingerprint)
+			}
+		})
+	}
+}
+
+func TestProviderData_requireHostKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		tofu     bool
+		recorded types.String
+		wantErr  bool
+	}{
+		{name: "strict", recorded: types.StringNull()},
+		{name: "recorded", tofu: true, recorded: types.StringValue(testFingerprint)},
+		{name: "nothing recorded", tofu: true, recorded: types.StringNull(), wantErr: true},
+		{name: "empty", tofu: true, recorded: types.StringValue(""), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &providerData{tofu: tt.tofu}
+			diags := d.requireHostKey(tt.recorded, "run the destroy commands")
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("requireHostKey() diagnostics = %v, want error %t", diags, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// JumpHostModel describes a single hop of a jump host chain.
+type JumpHostModel struct {
+	Host          types.String `tfsdk:"host"`
+	Port          types.String `tfsdk:"port"`
+	User          types.String `tfsdk:"user"`
+	Password      types.String `tfsdk:"password"`
+	PrivateKey    types.String `tfsdk:"private_key"`
+	Agent         types.Bool   `tfsdk:"agent"`
+	AgentSocket   types.String `tfsdk:"agent_socket"`
+	AgentIdentity types.String `tfsdk:"agent_identity"`
+
+	KnownHosts          types.String `tfsdk:"known_hosts"`
+	KnownHostsFiles     types.List   `tfsdk:"known_hosts_files"`
+	HostKeyFingerprints types.List   `tfsdk:"host_key_fingerprints"`
+}
+
+func jumpHostBlock() schema.ListNestedBlock {
+	return schema.ListNestedBlock{
+		MarkdownDescription: "Jump hosts to tunnel through, in order, before reaching `host`. Behaves like `ssh -J`. Jump hosts that set none of `known_hosts`, `known_hosts_files` and `host_key_fingerprints` are verified with those of the provider or connection profile.",
+		NestedObject: schema.NestedBlockObject{
+			Attributes: map[string]schema.Attribute{
+				"host": schema.StringAttribute{
+					Required: true,
+				},
+				"port": schema.StringAttribute{
+					Optional: true,
+				},
+				"user": schema.StringAttribute{
+					MarkdownDescription: "User on the jump host. Defaults to the provider `user`.",
+					Optional:            true,
+				},
+				"password": schema.StringAttribute{
+					Optional:  true,
+					Sensitive: true,
+				},
+				"private_key": schema.StringAttribute{
+					Optional:  true,
+					Sensitive: true,
+				},
+				"agent": schema.BoolAttribute{
+					Optional: true,
+				},
+				"agent_socket": schema.StringAttribute{
+					Optional: true,
+				},
+				"agent_identity": schema.StringAttribute{
+					Optional: true,
+				},
+				"known_hosts": schema.StringAttribute{
+					Optional: true,
+				},
+				"known_hosts_files": schema.ListAttribute{
+					ElementType: types.StringType,
+					Optional:    true,
+				},
+				"host_key_fingerprints": schema.ListAttribute{
+					ElementType: types.StringType,
+					Optional:    true,
+				},
+			},
+		},
+	}
+}
+
+// config returns the settings of the hop. The user and the host key
+// settings of the host the chain leads to apply unless the hop sets its own.
+func (m JumpHostModel) config(ctx context.Context, user string, hostKeys remote.HostKeyConfig) (*remote.Config, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if !m.KnownHosts.IsNull() || !m.KnownHostsFiles.IsNull() || !m.HostKeyFingerprints.IsNull() {
+		hostKeys, diags = hostKeyConfig(ctx, m.KnownHosts, m.KnownHostsFiles, m.HostKeyFingerprints)
+	}
+
+	if !m.User.IsNull() {
+		user = m.User.ValueString()
+	}
+
+	return &remote.Config{
+		Host:       m.Host.ValueString(),
+		Port:       m.Port.ValueString(),
+		User:       user,
+		Password:   m.Password.ValueString(),
+		PrivateKey: m.PrivateKey.ValueString(),
+		HostKeys:   hostKeys,
+
+		Agent:         m.Agent.ValueBool(),
+		AgentSocket:   m.AgentSocket.ValueString(),
+		AgentIdentity: m.AgentIdentity.ValueString(),
+	}, diags
+}
+
+func hostKeyConfig(ctx context.Context, knownHosts types.String, knownHostsFiles types.List, fingerprints types.List) (remote.HostKeyConfig, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var hostKeys remote.HostKeyConfig
+
+	if !knownHosts.IsNull() {
+		hostKeys.KnownHosts = append(hostKeys.KnownHosts, knownHosts.ValueString())
+	}
+
+	if !knownHostsFiles.IsNull() {
+		diags.Append(knownHostsFiles.ElementsAs(ctx, &hostKeys.KnownHostsFiles, false)...)
+	}
+	if !fingerprints.IsNull() {
+		diags.Append(fingerprints.ElementsAs(ctx, &hostKeys.Fingerprints, false)...)
+	}
+
+	return hostKeys, diags
+}
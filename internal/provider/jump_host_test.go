@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestJumpHostModel_config(t *testing.T) {
+	provider := remote.HostKeyConfig{
+		KnownHostsFiles: []string{"~/.ssh/known_hosts"},
+		Fingerprints:    []string{testFingerprint},
+	}
+	bastion := "SHA256:bastionbastionbastionbastionbastionbastion0"
+
+	tests := []struct {
+		name     string
+		jumpHost JumpHostModel
+		wantUser string
+		want     remote.HostKeyConfig
+	}{
+		{
+			name: "without host key settings",
+			jumpHost: JumpHostModel{
+				Host:                types.StringValue("bastion.example.com"),
+				KnownHostsFiles:     types.ListNull(types.StringType),
+				HostKeyFingerprints: types.ListNull(types.StringType),
+			},
+			wantUser: "deploy",
+			want:     provider,
+		},
+		{
+			name: "own fingerprints",
+			jumpHost: JumpHostModel{
+				Host:                types.StringValue("bastion.example.com"),
+				User:                types.StringValue("jump"),
+				KnownHostsFiles:     types.ListNull(types.StringType),
+				HostKeyFingerprints: types.ListValueMust(types.StringType, []attr.Value{types.StringValue(bastion)}),
+			},
+			wantUser: "jump",
+			want:     remote.HostKeyConfig{Fingerprints: []string{bastion}},
+		},
+		{
+			name: "own known hosts",
+			jumpHost: JumpHostModel{
+				Host:                types.StringValue("bastion.example.com"),
+				KnownHosts:          types.StringValue("bastion.example.com ssh-ed25519 AAAA"),
+				KnownHostsFiles:     types.ListNull(types.StringType),
+				HostKeyFingerprints: types.ListNull(types.StringType),
+			},
+			wantUser: "deploy",
+			want:     remote.HostKeyConfig{KnownHosts: []string{"bastion.example.com ssh-ed25519 AAAA"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := tt.jumpHost.config(context.Background(), "deploy", provider)
+			if diags.HasError() {
+				t.Fatalf("config() diagnostics = %v", diags)
+			}
+			if got.User != tt.wantUser {
+				t.Errorf("config() user = %q, want %q", got.User, tt.wantUser)
+			}
+			if !reflect.DeepEqual(got.HostKeys, tt.want) {
+				t.Errorf("config() host keys = %+v, want %+v", got.HostKeys, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// KeyboardInteractiveModel maps keyboard-interactive prompts to answers.
+type KeyboardInteractiveModel struct {
+	Prompts []PromptModel `tfsdk:"prompt"`
+}
+
+// PromptModel answers the prompts matching a regular expression.
+type PromptModel struct {
+	Pattern    types.String `tfsdk:"pattern"`
+	Answer     types.String `tfsdk:"answer"`
+	TOTPSecret types.String `tfsdk:"totp_secret"`
+}
+
+func keyboardInteractiveBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "Answers for keyboard-interactive authentication, tried after public keys so that servers requiring a second factor are supported.",
+		Blocks: map[string]schema.Block{
+			"prompt": schema.ListNestedBlock{
+				MarkdownDescription: "Answer for prompts matching `pattern`. The first matching prompt block is used.",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"pattern": schema.StringAttribute{
+							MarkdownDescription: "Regular expression matched against the prompt, e.g. `(?i)verification code`.",
+							Required:            true,
+						},
+						"answer": schema.StringAttribute{
+							MarkdownDescription: "Fixed answer to the prompt.",
+							Optional:            true,
+							Sensitive:           true,
+						},
+						"totp_secret": schema.StringAttribute{
+							MarkdownDescription: "Base32 TOTP secret. The answer is the current RFC 6238 code.",
+							Optional:            true,
+							Sensitive:           true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (m *KeyboardInteractiveModel) responses() ([]remote.PromptResponse, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if m == nil {
+		return nil, diags
+	}
+
+	responses := make([]remote.PromptResponse, 0, len(m.Prompts))
+	for i, p := range m.Prompts {
+		prompt := path.Root("keyboard_interactive").AtName("prompt").AtListIndex(i)
+
+		pattern, err := regexp.Compile(p.Pattern.ValueString())
+		if err != nil {
+			diags.AddAttributeError(prompt.AtName("pattern"), "Invalid Prompt Pattern", err.Error())
+			continue
+		}
+
+		if !p.TOTPSecret.IsNull() {
+			if _, err := remote.TOTP(p.TOTPSecret.ValueString(), time.Now()); err != nil {
+				diags.AddAttributeError(prompt.AtName("totp_secret"), "Invalid TOTP Secret", err.Error())
+				continue
+			}
+		}
+
+		responses = append(responses, remote.PromptResponse{
+			Pattern:    pattern,
+			Answer:     p.Answer.ValueString(),
+			TOTPSecret: p.TOTPSecret.ValueString(),
+		})
+	}
+	return responses, diags
+}
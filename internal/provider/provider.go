@@ -2,17 +2,21 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/appkins/terraform-provider-ssh/internal/remote"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-	"github.com/loafoe/easyssh-proxy/v2"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var (
@@ -25,6 +29,43 @@ type SshProviderModel struct {
 	User       types.String `tfsdk:"user"`
 	Password   types.String `tfsdk:"password"`
 	PrivateKey types.String `tfsdk:"private_key"`
+
+	PrivateKeyFile       types.String `tfsdk:"private_key_file"`
+	PrivateKeyPassphrase types.String `tfsdk:"private_key_passphrase"`
+	CredentialCommand    types.String `tfsdk:"credential_command"`
+	Certificate          types.String `tfsdk:"certificate"`
+
+	CertificateAuthority *CertificateAuthorityModel `tfsdk:"certificate_authority"`
+	KeyboardInteractive  *KeyboardInteractiveModel  `tfsdk:"keyboard_interactive"`
+
+	Algorithms *AlgorithmsModel `tfsdk:"algorithms"`
+
+	Agent         types.Bool   `tfsdk:"agent"`
+	AgentSocket   types.String `tfsdk:"agent_socket"`
+	AgentIdentity types.String `tfsdk:"agent_identity"`
+	ForwardAgent  types.Bool   `tfsdk:"forward_agent"`
+
+	Environment          types.Map `tfsdk:"environment"`
+	SensitiveEnvironment types.Map `tfsdk:"sensitive_environment"`
+
+	KnownHosts          types.String `tfsdk:"known_hosts"`
+	KnownHostsFiles     types.List   `tfsdk:"known_hosts_files"`
+	HostKeyFingerprints types.List   `tfsdk:"host_key_fingerprints"`
+	HostKeyPolicy       types.String `tfsdk:"host_key_policy"`
+
+	JumpHosts    []JumpHostModel `tfsdk:"jump_host"`
+	Proxy        *ProxyModel     `tfsdk:"proxy"`
+	ProxyCommand types.String    `tfsdk:"proxy_command"`
+
+	UseSSHConfig  types.Bool   `tfsdk:"use_ssh_config"`
+	SSHConfigFile types.String `tfsdk:"ssh_config_file"`
+
+	ConnectTimeout     types.String `tfsdk:"connect_timeout"`
+	CommandTimeout     types.String `tfsdk:"command_timeout"`
+	KeepaliveInterval  types.String `tfsdk:"keepalive_interval"`
+	KeepaliveMaxMissed types.Int64  `tfsdk:"keepalive_max_missed"`
+
+	Connections []ConnectionProfileModel `tfsdk:"connection"`
 }
 
 func New() provider.Provider {
@@ -41,7 +82,8 @@ func (p *frameworkProvider) Schema(_ context.Context, _ provider.SchemaRequest,
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"host": schema.StringAttribute{
-				Required: true,
+				MarkdownDescription: "Host to connect to. Can be omitted when resources select a `connection` profile with `connection_profile` or set the host in their own `connection` block.",
+				Optional:            true,
 			},
 			"port": schema.StringAttribute{
 				Optional: true,
@@ -57,6 +99,104 @@ func (p *frameworkProvider) Schema(_ context.Context, _ provider.SchemaRequest,
 				Optional:  true,
 				Sensitive: true,
 			},
+			"private_key_file": schema.StringAttribute{
+				MarkdownDescription: "Path to the private key file. A leading `~` is expanded. Conflicts with `private_key`.",
+				Optional:            true,
+			},
+			"private_key_passphrase": schema.StringAttribute{
+				MarkdownDescription: "Passphrase for an encrypted OpenSSH, PEM or PKCS#8 private key.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"credential_command": schema.StringAttribute{
+				MarkdownDescription: "Local command that prints a JSON document with `password`, `private_key` and `private_key_passphrase` keys. Its values are used for any of `password`, `private_key` and `private_key_passphrase` not set in the configuration.",
+				Optional:            true,
+			},
+			"certificate": schema.StringAttribute{
+				MarkdownDescription: "OpenSSH user certificate, as content in `authorized_keys` format or a path to a `-cert.pub` file. It is offered together with the key it certifies from `private_key` or the agent.",
+				Optional:            true,
+			},
+			"agent": schema.BoolAttribute{
+				MarkdownDescription: "Authenticate with keys held by an SSH agent. Keys are offered in the order `certificate_authority`, `private_key` or `private_key_file`, the `IdentityFile` entries of the OpenSSH client config when `use_ssh_config` is set, `agent`, followed by `keyboard_interactive` and `password` authentication.",
+				Optional:            true,
+			},
+			"agent_socket": schema.StringAttribute{
+				MarkdownDescription: "Path to the SSH agent socket. Defaults to the `SSH_AUTH_SOCK` environment variable.",
+				Optional:            true,
+			},
+			"agent_identity": schema.StringAttribute{
+				MarkdownDescription: "Only offer the agent key whose comment, SHA256 fingerprint or public key matches this value.",
+				Optional:            true,
+			},
+			"forward_agent": schema.BoolAttribute{
+				MarkdownDescription: "Forward an SSH agent to every command. The local agent is forwarded when `agent` is set, otherwise an in-memory agent holding `private_key`. Can also be set per `exec` block.",
+				Optional:            true,
+			},
+			"environment": schema.MapAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Environment variables set for every command. Variables the server refuses to set, e.g. because of `AcceptEnv` in `sshd_config`, are set with `env` on the command line instead, which runs the command with `sh`. `exec` blocks can override them.",
+				Optional:            true,
+			},
+			"sensitive_environment": schema.MapAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Like `environment`, for secrets: their values are masked in the provider logs, and those the server refuses to set are passed over stdin rather than on the command line.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"known_hosts": schema.StringAttribute{
+				MarkdownDescription: "Content in `known_hosts` format used to verify the server host key. `@cert-authority` lines and hashed hostnames are supported.",
+				Optional:            true,
+			},
+			"known_hosts_files": schema.ListAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Paths to `known_hosts` files used to verify the server host key.",
+				Optional:            true,
+			},
+			"host_key_fingerprints": schema.ListAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "SHA256 fingerprints of accepted server host keys, e.g. `SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8`.",
+				Optional:            true,
+			},
+			"host_key_policy": schema.StringAttribute{
+				MarkdownDescription: "How server host keys are trusted. `strict`, the default, verifies them against `known_hosts`, `known_hosts_files` and `host_key_fingerprints`. `tofu` trusts the key presented when a resource is created and records its fingerprint in the resource's `host_key_fingerprint`; later operations on the resource refuse a different key until its `host_key_rotation` changes.",
+				Optional:            true,
+			},
+			"proxy_command": schema.StringAttribute{
+				MarkdownDescription: "Local command used instead of a TCP connection to reach the first SSH host, like OpenSSH's `ProxyCommand`. The SSH protocol is spoken over its stdin and stdout. `%h`, `%p` and `%r` expand to the host, port and user. Takes precedence over `proxy`.",
+				Optional:            true,
+			},
+			"use_ssh_config": schema.BoolAttribute{
+				MarkdownDescription: "Resolve `host` through the OpenSSH client config, applying `HostName`, `User`, `Port`, `IdentityFile`, `ProxyJump`, `UserKnownHostsFile` and `ConnectTimeout`. Attributes set in the provider configuration take precedence. `ProxyJump` hops without a `UserKnownHostsFile` are verified with the provider host key settings, or else `~/.ssh/known_hosts`, and are not sent the password or keyboard-interactive answers when none of these exist.",
+				Optional:            true,
+			},
+			"connect_timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout for establishing each SSH connection, including the handshake. Defaults to `20s`.",
+				Optional:            true,
+			},
+			"command_timeout": schema.StringAttribute{
+				MarkdownDescription: "Time after which a running command is killed, unless the resource sets its own `timeout`. Defaults to `5m`; `0s` disables the limit.",
+				Optional:            true,
+			},
+			"keepalive_interval": schema.StringAttribute{
+				MarkdownDescription: "Interval between `keepalive@openssh.com` requests, which keep idle connections open through NAT gateways and detect dead ones. Defaults to `30s`; `0s` disables keepalives.",
+				Optional:            true,
+			},
+			"keepalive_max_missed": schema.Int64Attribute{
+				MarkdownDescription: "Number of unanswered keepalive requests after which a connection is declared dead. Defaults to `3`.",
+				Optional:            true,
+			},
+			"ssh_config_file": schema.StringAttribute{
+				MarkdownDescription: "Path to the OpenSSH client config. Defaults to `~/.ssh/config`. Setting it implies `use_ssh_config`.",
+				Optional:            true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"jump_host":             jumpHostBlock(),
+			"proxy":                 proxyBlock(),
+			"certificate_authority": certificateAuthorityBlock(),
+			"keyboard_interactive":  keyboardInteractiveBlock(),
+			"algorithms":            algorithmsBlock(),
+			"connection":            connectionProfileBlock(),
 		},
 	}
 }
@@ -70,37 +210,46 @@ func (p *frameworkProvider) Configure(ctx context.Context, req provider.Configur
 		return
 	}
 
-	// If practitioner provided a configuration value for any of the
-	// attributes, it must be a known value.
-
-	if config.Host.IsUnknown() {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("host"),
-			"Unknown HashiCups API Host",
-			"The provider cannot create the HashiCups API client as there is an unknown configuration value for the HashiCups API host. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the HASHICUPS_HOST environment variable.",
-		)
+	// Left unset until the OpenSSH client config had a chance to set it.
+	connectTimeout, diags := parseDuration(config.ConnectTimeout, "connect_timeout", 0)
+	resp.Diagnostics.Append(diags...)
+	commandTimeout, diags := parseDuration(config.CommandTimeout, "command_timeout", 5*time.Minute)
+	resp.Diagnostics.Append(diags...)
+	keepaliveInterval, diags := parseDuration(config.KeepaliveInterval, "keepalive_interval", 30*time.Second)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	if config.User.IsUnknown() {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("username"),
-			"Unknown HashiCups API User",
-			"The provider cannot create the HashiCups API client as there is an unknown configuration value for the HashiCups API username. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the HASHICUPS_USERNAME environment variable.",
-		)
-	}
+	retryDelay := 20 * time.Second
 
-	if config.Password.IsUnknown() && config.PrivateKey.IsUnknown() {
+	switch config.HostKeyPolicy.ValueString() {
+	case "", hostKeyPolicyStrict, hostKeyPolicyTOFU:
+	default:
 		resp.Diagnostics.AddAttributeError(
-			path.Root("password"),
-			"Unknown HashiCups API Password",
-			"The provider cannot create the HashiCups API client as there is an unknown configuration value for the HashiCups API password. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the HASHICUPS_PASSWORD environment variable.",
+			path.Root("host_key_policy"),
+			"Invalid Host Key Policy",
+			"The host key policy must be \""+hostKeyPolicyStrict+"\" or \""+hostKeyPolicyTOFU+"\".",
 		)
+		return
 	}
 
-	if resp.Diagnostics.HasError() {
+	// Values computed by other resources in the same apply are unknown
+	// during plan. Defer connecting until they are resolved, failing only
+	// operations that actually need the connection. Unknown values of a
+	// connection profile only defer the resources using that profile.
+	unknown := unknownAttributes(req.Config.Raw)
+	unresolvedProfiles, others := profileAttributes(unknown, config.Connections)
+	if len(others) > 0 {
+		tflog.Info(ctx, "Provider configuration is not fully known, deferring connection setup", map[string]interface{}{"unknown": unknown})
+		data := &providerData{
+			tofu: config.HostKeyPolicy.ValueString() == hostKeyPolicyTOFU,
+			unresolved: remote.NewLazyProvisioner(func(context.Context) (*remote.Config, error) {
+				return nil, &remote.UnresolvedError{Attributes: unknown}
+			}, commandTimeout, retryDelay),
+		}
+		resp.DataSourceData = data
+		resp.ResourceData = data
 		return
 	}
 
@@ -128,72 +277,394 @@ func (p *frameworkProvider) Configure(ctx context.Context, req provider.Configur
 		private_key = config.PrivateKey.ValueString()
 	}
 
+	passphrase := config.PrivateKeyPassphrase.ValueString()
+
+	if !config.PrivateKeyFile.IsNull() {
+		if !config.PrivateKey.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("private_key_file"),
+				"Conflicting Private Key",
+				"Only one of private_key and private_key_file can be set.",
+			)
+			return
+		}
+		key, err := os.ReadFile(remote.ExpandPath(config.PrivateKeyFile.ValueString()))
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("private_key_file"),
+				"Unreadable Private Key File",
+				"The provider cannot read the private key file: "+err.Error(),
+			)
+			return
+		}
+		private_key = string(key)
+	}
+
+	// Secrets from a credential helper fill in what the configuration
+	// leaves unset, taking precedence over the environment.
+	if !config.CredentialCommand.IsNull() {
+		creds, err := remote.RunCredentialCommand(ctx, config.CredentialCommand.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("credential_command"),
+				"Credential Command Failed",
+				err.Error(),
+			)
+			return
+		}
+		if config.Password.IsNull() && creds.Password != "" {
+			password = creds.Password
+		}
+		if config.PrivateKey.IsNull() && config.PrivateKeyFile.IsNull() && creds.PrivateKey != "" {
+			private_key = creds.PrivateKey
+			if config.PrivateKeyPassphrase.IsNull() {
+				passphrase = creds.Passphrase
+			}
+		}
+	}
+
+	certificate := config.Certificate.ValueString()
+	if certificate != "" && !strings.Contains(certificate, "-cert-v01@openssh.com") {
+		content, err := os.ReadFile(remote.ExpandPath(certificate))
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("certificate"),
+				"Unreadable Certificate File",
+				"The provider cannot read the certificate file: "+err.Error(),
+			)
+			return
+		}
+		certificate = string(content)
+	}
+
+	if private_key != "" {
+		if _, err := remote.ParsePrivateKey([]byte(private_key), passphrase); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("private_key"),
+				"Invalid Private Key",
+				"The provider cannot parse the private key: "+err.Error(),
+			)
+			return
+		}
+	}
+
+	hostKeys, diags := hostKeyConfig(ctx, config.KnownHosts, config.KnownHostsFiles, config.HostKeyFingerprints)
+	resp.Diagnostics.Append(diags...)
+
+	algorithms, diags := config.Algorithms.algorithms(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	jumpHosts := make([]*remote.Config, 0, len(config.JumpHosts))
+	for _, j := range config.JumpHosts {
+		jumpHost, diags := j.config(ctx, user, hostKeys)
+		resp.Diagnostics.Append(diags...)
+		jumpHost.Timeout = connectTimeout
+		jumpHost.Algorithms = algorithms
+		jumpHosts = append(jumpHosts, jumpHost)
+	}
+
+	proxy, diags := config.Proxy.config(ctx)
+	resp.Diagnostics.Append(diags...)
+
+	prompts, diags := config.KeyboardInteractive.responses()
+	resp.Diagnostics.Append(diags...)
+
+	env, diags := environment(ctx, config.Environment, path.Root("environment"))
+	resp.Diagnostics.Append(diags...)
+
+	sensitiveEnv, diags := environment(ctx, config.SensitiveEnvironment, path.Root("sensitive_environment"))
+	resp.Diagnostics.Append(diags...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	defaults := &remote.Config{
+		Host:       host,
+		Port:       config.Port.ValueString(),
+		User:       user,
+		Password:   password,
+		PrivateKey: private_key,
+		Passphrase: passphrase,
+		Timeout:    connectTimeout,
+		HostKeys:   hostKeys,
+		Algorithms: algorithms,
+
+		Certificate: certificate,
+
+		KeyboardInteractive: prompts,
+
+		Agent:         config.Agent.ValueBool(),
+		AgentSocket:   config.AgentSocket.ValueString(),
+		AgentIdentity: config.AgentIdentity.ValueString(),
+		ForwardAgent:  config.ForwardAgent.ValueBool(),
+
+		Environment:          env,
+		SensitiveEnvironment: sensitiveEnv,
+
+		JumpHosts: jumpHosts,
+		Proxy:     proxy,
+
+		ProxyCommand: config.ProxyCommand.ValueString(),
+
+		KeepaliveInterval:  keepaliveInterval,
+		KeepaliveMaxMissed: int(config.KeepaliveMaxMissed.ValueInt64()),
+
+		// Resources share connections to the same host and credentials,
+		// opening a session per command or file copy.
+		Pool: remote.NewPool(2 * time.Minute),
+	}
+
+	data := &providerData{
+		config:     config,
+		timeout:    commandTimeout,
+		retryDelay: retryDelay,
+		tofu:       config.HostKeyPolicy.ValueString() == hostKeyPolicyTOFU,
+		profiles:   make(map[string]*remote.Provisioner, len(config.Connections)),
+		bases:      map[string]*remote.Config{"": defaults},
+
+		unresolvedProfiles: make(map[string]bool, len(unresolvedProfiles)),
+	}
+
+	for i, c := range config.Connections {
+		root := path.Root("connection").AtListIndex(i)
+		name := c.Name.ValueString()
+		if _, ok := data.profiles[name]; ok {
+			resp.Diagnostics.AddAttributeError(root.AtName("name"), "Duplicate Connection Profile", "Another connection profile is already named "+name+".")
+			continue
+		}
+		if attributes, ok := unresolvedProfiles[name]; ok {
+			tflog.Info(ctx, "Connection profile is not fully known, deferring its connection setup", map[string]interface{}{"profile": name, "unknown": attributes})
+			data.unresolvedProfiles[name] = true
+			data.profiles[name] = remote.NewLazyProvisioner(func(context.Context) (*remote.Config, error) {
+				return nil, &remote.UnresolvedError{Attributes: attributes}
+			}, commandTimeout, retryDelay)
+			continue
+		}
+
+		base, diags := c.config(ctx, defaults, root)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			continue
+		}
+		data.bases[name] = base
+
+		ssh := base.Clone()
+		diags = data.finalizeConnection(ctx, ssh, root)
+		resp.Diagnostics.Append(diags...)
+		data.profiles[name] = remote.NewProvisioner(ssh, commandTimeout, retryDelay)
+	}
+
+	if host == "" {
+		// Resources name their host in a connection block or select a
+		// connection profile.
+		data.defaults = remote.NewLazyProvisioner(func(context.Context) (*remote.Config, error) {
+			return nil, fmt.Errorf("no host is configured on the provider, set connection_profile or the host in the resource connection block")
+		}, commandTimeout, retryDelay)
+	} else {
+		ssh := defaults.Clone()
+		resp.Diagnostics.Append(data.finalizeConnection(ctx, ssh, path.Empty())...)
+		data.defaults = remote.NewProvisioner(ssh, commandTimeout, retryDelay)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.DataSourceData = data
+	resp.ResourceData = data
+}
+
+// defaultConnectTimeout applies when neither connect_timeout nor the
+// OpenSSH client config set a timeout.
+const defaultConnectTimeout = 20 * time.Second
+
+// finalizeConnection prepares a connection for use. It resolves the host
+// through the OpenSSH client config, signs a certificate for the user and
+// checks that the connection can authenticate and verify host keys. root is
+// the path of the connection block, or an empty path for the provider itself.
+func (d *providerData) finalizeConnection(ctx context.Context, ssh *remote.Config, root path.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := d.config
+
+	jumpHosts := len(ssh.JumpHosts)
+	if ssh.Host != "" {
+		if err := applySSHConfig(config, ssh); err != nil {
+			diags.AddAttributeError(
+				path.Root("ssh_config_file"),
+				"Invalid SSH Config",
+				"The provider cannot read the OpenSSH client config: "+err.Error(),
+			)
+			return diags
+		}
+	}
+	if ssh.Timeout == 0 {
+		ssh.Timeout = defaultConnectTimeout
+	}
+	for i, hop := range ssh.JumpHosts {
+		if hop.Timeout == 0 {
+			// Hops may be shared with the settings this connection was
+			// cloned from.
+			hop = hop.Clone()
+			hop.Timeout = ssh.Timeout
+			ssh.JumpHosts[i] = hop
+		}
+	}
+
+	if config.CertificateAuthority != nil && !config.CertificateAuthority.PrivateKey.IsNull() {
+		signer, signerDiags := d.certificate(ctx, ssh.User)
+		diags.Append(signerDiags...)
+		if diags.HasError() {
+			return diags
+		}
+		ssh.Signers = append(ssh.Signers, signer)
+		// Hops added from ProxyJump authenticate with the same credentials.
+		for _, hop := range ssh.JumpHosts[jumpHosts:] {
+			hop.Signers = append(hop.Signers, signer)
+		}
+	}
+
 	// If any of the expected configurations are missing, return
 	// errors with provider-specific guidance.
 
-	if host == "" {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("host"),
-			"Missing HashiCups API Host",
-			"The provider cannot create the HashiCups API client as there is a missing or empty value for the HashiCups API host. "+
-				"Set the host value in the configuration or use the HASHICUPS_HOST environment variable. "+
+	if ssh.Host == "" {
+		diags.AddAttributeError(
+			root.AtName("host"),
+			"Missing SSH Host",
+			"The provider cannot connect as there is a missing or empty value for the SSH host. "+
+				"Set the host value in the configuration or use the SSH_HOST environment variable. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
 
-	if user == "" {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("username"),
-			"Missing HashiCups API User",
-			"The provider cannot create the HashiCups API client as there is a missing or empty value for the HashiCups API username. "+
-				"Set the username value in the configuration or use the HASHICUPS_USERNAME environment variable. "+
+	if ssh.User == "" {
+		diags.AddAttributeError(
+			root.AtName("user"),
+			"Missing SSH User",
+			"The provider cannot connect as there is a missing or empty value for the SSH user. "+
+				"Set the user value in the configuration or use the SSH_USER environment variable. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
 
-	if password == "" {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("password"),
-			"Missing HashiCups API Password",
-			"The provider cannot create the HashiCups API client as there is a missing or empty value for the HashiCups API password. "+
-				"Set the password value in the configuration or use the HASHICUPS_PASSWORD environment variable. "+
+	if ssh.Password == "" && ssh.PrivateKey == "" && len(ssh.IdentityFiles) == 0 && len(ssh.Signers) == 0 && len(ssh.KeyboardInteractive) == 0 && !ssh.Agent {
+		diags.AddError(
+			"Missing SSH Authentication",
+			"The provider cannot connect to "+ssh.Host+" as no authentication method is configured. "+
+				"Set password, private_key, agent or certificate_authority in the configuration, or use the SSH_PASSWORD or SSH_PRIVATE_KEY environment variables. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
 
-	if resp.Diagnostics.HasError() {
-		return
+	if diags.HasError() {
+		return diags
 	}
 
-	t1, _ := time.ParseDuration("20s")
+	if ssh.Certificate != "" {
+		cert, err := remote.ParseCertificate([]byte(ssh.Certificate))
+		if err == nil {
+			err = remote.CheckCertificate(cert, ssh.User, time.Now())
+		}
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("certificate"),
+				"Invalid Certificate",
+				"The provider cannot authenticate with the certificate: "+err.Error(),
+			)
+			return diags
+		}
+	}
 
-	client := remote.NewProvisioner(&easyssh.MakeConfig{
-		User:     user,
-		Server:   host,
-		Password: password,
-		Key:      private_key,
-	}, t1, t1)
+	// Fail early on unreadable known_hosts sources rather than on first use.
+	if _, err := ssh.HostKeys.Callback(); err != nil {
+		diags.AddAttributeError(
+			root.AtName("known_hosts_files"),
+			"Invalid Host Key Configuration",
+			"The provider cannot load the configured known_hosts entries: "+err.Error(),
+		)
+		return diags
+	}
 
-	//client := operator.NewSSHOperator()
+	for i, j := range ssh.JumpHosts {
+		if _, err := j.HostKeys.Callback(); err != nil {
+			diags.AddAttributeError(
+				root.AtName("jump_host").AtListIndex(i).AtName("known_hosts_files"),
+				"Invalid Host Key Configuration",
+				"The provider cannot load the configured known_hosts entries: "+err.Error(),
+			)
+			return diags
+		}
+	}
 
-	// client.Ssh.KeyPath = "~/.ssh/id_ed25519"
+	if ssh.HostKeys.IsEmpty() && config.HostKeyPolicy.ValueString() != hostKeyPolicyTOFU {
+		tflog.Warn(ctx, "No known_hosts or host_key_fingerprints configured, server host keys will not be verified", map[string]interface{}{"host": ssh.Host})
+	}
+	// Host keys recorded on first use only ever pin the final host.
+	for _, j := range ssh.JumpHosts {
+		if j.HostKeys.IsEmpty() {
+			tflog.Warn(ctx, "No known_hosts or host_key_fingerprints configured, jump host keys will not be verified", map[string]interface{}{"host": j.Host})
+		}
+	}
+	return diags
+}
 
-	// Create a new HashiCups client using the configuration values
-	// client, err := client.AddClient(cfg.Host.String(), &ssh.ClientConfig{User: user, Auth: []ssh.AuthMethod{ssh.Password(password)}})
-	// if err != nil {
-	// 	resp.Diagnostics.AddError(
-	// 		"Unable to Create HashiCups API Client",
-	// 		"An unexpected error occurred when creating the HashiCups API client. "+
-	// 			"If the error is not clear, please contact the provider developers.\n\n"+
-	// 			"HashiCups Client Error: "+err.Error(),
-	// 	)
-	// 	return
-	// }
+// parseDuration parses a duration attribute, returning def when it is unset.
+func parseDuration(value types.String, name string, def time.Duration) (time.Duration, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if value.IsNull() || value.IsUnknown() {
+		return def, diags
+	}
+	d, err := time.ParseDuration(value.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root(name), "Invalid Duration", err.Error())
+	}
+	return d, diags
+}
 
-	// Make the HashiCups client available during DataSource and Resource
-	// type Configure methods.
-	resp.DataSourceData = client
-	resp.ResourceData = client
+// unknownAttributes returns the paths of the configuration values that are
+// not yet known, e.g. "host" or "jump_host[0].user".
+func unknownAttributes(config tftypes.Value) []string {
+	var unknown []string
+	_ = tftypes.Walk(config, func(p *tftypes.AttributePath, v tftypes.Value) (bool, error) {
+		if v.IsKnown() {
+			return true, nil
+		}
+		var name strings.Builder
+		for _, step := range p.Steps() {
+			switch step := step.(type) {
+			case tftypes.AttributeName:
+				if name.Len() > 0 {
+					name.WriteString(".")
+				}
+				name.WriteString(string(step))
+			case tftypes.ElementKeyInt:
+				fmt.Fprintf(&name, "[%d]", int64(step))
+			case tftypes.ElementKeyString:
+				fmt.Fprintf(&name, "[%q]", string(step))
+			}
+		}
+		unknown = append(unknown, name.String())
+		return false, nil
+	})
+	return unknown
+}
+
+// profileAttributes takes the unknown attributes of the connection profiles
+// out of unknown, by profile name. Those of profiles whose name is itself
+// unknown stay in the others, as any resource could be using them.
+func profileAttributes(unknown []string, profiles []ConnectionProfileModel) (map[string][]string, []string) {
+	byProfile := map[string][]string{}
+	var others []string
+	for _, attribute := range unknown {
+		var i int
+		if _, err := fmt.Sscanf(attribute, "connection[%d]", &i); err == nil && i < len(profiles) && !profiles[i].Name.IsUnknown() {
+			name := profiles[i].Name.ValueString()
+			byProfile[name] = append(byProfile[name], attribute)
+			continue
+		}
+		others = append(others, attribute)
+	}
+	return byProfile, others
 }
 
 func (p *frameworkProvider) Resources(_ context.Context) []func() resource.Resource {
@@ -204,6 +675,6 @@ func (p *frameworkProvider) Resources(_ context.Context) []func() resource.Resou
 
 func (p *frameworkProvider) DataSources(_ context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
-		//NewExampleDataSource,
+		NewTunnelDataSource,
 	}
 }
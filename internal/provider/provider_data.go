@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"golang.org/x/crypto/ssh"
+)
+
+// providerData is passed to resources and data sources as ProviderData.
+type providerData struct {
+	config     SshProviderModel
+	timeout    time.Duration
+	retryDelay time.Duration
+	// tofu records host keys in resource state on first use.
+	tofu bool
+
+	// defaults connects to the provider host.
+	defaults *remote.Provisioner
+	// profiles connect to the hosts of the named connection profiles.
+	profiles map[string]*remote.Provisioner
+	// bases hold the settings of the provider, under the empty name, and of
+	// each profile before they are finalized, for resource connection
+	// blocks to be merged over.
+	bases map[string]*remote.Config
+	// unresolved is used for every connection while the provider
+	// configuration contains unknown values.
+	unresolved *remote.Provisioner
+	// unresolvedProfiles are the connection profiles whose configuration
+	// contains unknown values. Their provisioners fail with the unknown
+	// attributes until it is resolved.
+	unresolvedProfiles map[string]bool
+
+	// certificates caches the signers of the certificate_authority by
+	// user, so that connections share their key and their pool entries.
+	certificatesMu sync.Mutex
+	certificates   map[string]ssh.Signer
+}
+
+// certificate returns the signer presenting a certificate for user, signing
+// it on first use.
+func (d *providerData) certificate(ctx context.Context, user string) (ssh.Signer, diag.Diagnostics) {
+	d.certificatesMu.Lock()
+	defer d.certificatesMu.Unlock()
+
+	if signer, ok := d.certificates[user]; ok {
+		return signer, nil
+	}
+	signer, diags := d.config.CertificateAuthority.signer(ctx, user)
+	if diags.HasError() {
+		return nil, diags
+	}
+	if d.certificates == nil {
+		d.certificates = map[string]ssh.Signer{}
+	}
+	d.certificates[user] = signer
+	return signer, diags
+}
+
+// provisioner returns the provisioner for the named connection profile, or
+// for the provider host when name is empty.
+func (d *providerData) provisioner(name string) (*remote.Provisioner, error) {
+	if d.unresolved != nil {
+		return d.unresolved, nil
+	}
+	if name == "" {
+		return d.defaults, nil
+	}
+	p, ok := d.profiles[name]
+	if !ok {
+		return nil, d.unknownProfile(name)
+	}
+	return p, nil
+}
+
+// connection returns the provisioner for the named connection profile with a
+// resource connection block merged over it.
+func (d *providerData) connection(ctx context.Context, name string, override *ConnectionModel) (*remote.Provisioner, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if override == nil || d.unresolved != nil || d.unresolvedProfiles[name] {
+		p, err := d.provisioner(name)
+		if err != nil {
+			diags.AddAttributeError(path.Root("connection_profile"), "Unknown Connection Profile", err.Error())
+		}
+		return p, diags
+	}
+
+	base, ok := d.bases[name]
+	if !ok {
+		diags.AddAttributeError(path.Root("connection_profile"), "Unknown Connection Profile", d.unknownProfile(name).Error())
+		return nil, diags
+	}
+
+	ssh := base.Clone()
+	diags.Append(override.apply(ctx, ssh)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	diags.Append(d.finalizeConnection(ctx, ssh, path.Root("connection"))...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	return remote.NewProvisioner(ssh, d.timeout, d.retryDelay), diags
+}
+
+func (d *providerData) unknownProfile(name string) error {
+	names := make([]string, 0, len(d.profiles))
+	for n := range d.profiles {
+		names = append(names, fmt.Sprintf("%q", n))
+	}
+	sort.Strings(names)
+	return fmt.Errorf("no connection profile named %q is configured on the provider, expected one of [%s]", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestProviderData_provisioner(t *testing.T) {
+	defaults := remote.NewProvisioner(testDefaults(), time.Minute, 0)
+	db := remote.NewProvisioner(&remote.Config{Host: "db.example.com"}, time.Minute, 0)
+	web := remote.NewProvisioner(&remote.Config{Host: "web.example.com"}, time.Minute, 0)
+	data := &providerData{
+		defaults: defaults,
+		profiles: map[string]*remote.Provisioner{"db": db, "web": web},
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		want    *remote.Provisioner
+		wantErr string
+	}{
+		{name: "provider host", profile: "", want: defaults},
+		{name: "profile", profile: "db", want: db},
+		{name: "unknown profile", profile: "cache", wantErr: `no connection profile named "cache" is configured on the provider, expected one of ["db", "web"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := data.provisioner(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("provisioner() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("provisioner() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("provisioner() = %+v, want %+v", got.Ssh, tt.want.Ssh)
+			}
+		})
+	}
+
+	unresolved := remote.NewLazyProvisioner(nil, time.Minute, 0)
+	data.unresolved = unresolved
+	if got, err := data.provisioner("cache"); err != nil || got != unresolved {
+		t.Errorf("provisioner() while unresolved = %v, %v, want the unresolved provisioner", got, err)
+	}
+}
+
+func TestProviderData_connection(t *testing.T) {
+	base := testDefaults()
+	base.Certificate = ""
+	profile := base.Clone()
+	profile.Host, profile.Port = "db.example.com", "2200"
+
+	profileProvisioner := remote.NewProvisioner(profile, time.Minute, 0)
+	data := &providerData{
+		timeout:  time.Minute,
+		defaults: remote.NewProvisioner(base, time.Minute, 0),
+		profiles: map[string]*remote.Provisioner{"db": profileProvisioner},
+		bases:    map[string]*remote.Config{"": base, "db": profile},
+	}
+
+	tests := []struct {
+		name      string
+		profile   string
+		override  *ConnectionModel
+		wantHost  string
+		wantPort  string
+		wantUser  string
+		wantError string
+	}{
+		{name: "profile", profile: "db", wantHost: "db.example.com", wantPort: "2200", wantUser: "deploy"},
+		{name: "override over provider", override: &ConnectionModel{User: types.StringValue("root")}, wantHost: "provider.example.com", wantPort: "2222", wantUser: "root"},
+		{name: "override over profile", profile: "db", override: &ConnectionModel{User: types.StringValue("postgres")}, wantHost: "db.example.com", wantPort: "2200", wantUser: "postgres"},
+		{name: "host override over profile", profile: "db", override: &ConnectionModel{Host: types.StringValue("replica.example.com")}, wantHost: "replica.example.com", wantPort: "", wantUser: "deploy"},
+		{name: "unknown profile", profile: "cache", wantError: "Unknown Connection Profile"},
+		{name: "unknown profile with override", profile: "cache", override: &ConnectionModel{User: types.StringValue("root")}, wantError: "Unknown Connection Profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, diags := data.connection(context.Background(), tt.profile, tt.override)
+			if tt.wantError != "" {
+				if !diags.HasError() || diags.Errors()[0].Summary() != tt.wantError {
+					t.Fatalf("connection() diagnostics = %v, want %q", diags, tt.wantError)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("connection() diagnostics = %v", diags)
+			}
+			if got := p.Ssh; got.Host != tt.wantHost || got.Port != tt.wantPort || got.User != tt.wantUser {
+				t.Errorf("connection() = %s@%s:%s, want %s@%s:%s", got.User, got.Host, got.Port, tt.wantUser, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+
+	if profile.User != "deploy" || base.User != "deploy" {
+		t.Errorf("connection() modified the profile settings")
+	}
+}
+
+func TestProviderData_connectionUnresolvedProfile(t *testing.T) {
+	pending := remote.NewLazyProvisioner(func(context.Context) (*remote.Config, error) {
+		return nil, &remote.UnresolvedError{Attributes: []string{"connection[1].host"}}
+	}, time.Minute, 0)
+	base := testDefaults()
+	base.Certificate = ""
+	data := &providerData{
+		timeout:            time.Minute,
+		defaults:           remote.NewProvisioner(base, time.Minute, 0),
+		profiles:           map[string]*remote.Provisioner{"pending": pending},
+		bases:              map[string]*remote.Config{"": base},
+		unresolvedProfiles: map[string]bool{"pending": true},
+	}
+
+	override := &ConnectionModel{User: types.StringValue("root")}
+	for _, profile := range []string{"", "pending"} {
+		p, diags := data.connection(context.Background(), profile, override)
+		if diags.HasError() {
+			t.Fatalf("connection(%q) diagnostics = %v", profile, diags)
+		}
+		_, err := p.Connection(context.Background())
+		var unresolved *remote.UnresolvedError
+		if got := errors.As(err, &unresolved); got != (profile == "pending") {
+			t.Errorf("connection(%q) unresolved = %v, error = %v", profile, got, err)
+		}
+	}
+}
+
+func TestProfileAttributes(t *testing.T) {
+	profiles := []ConnectionProfileModel{
+		{Name: types.StringValue("db")},
+		{Name: types.StringUnknown()},
+	}
+
+	tests := []struct {
+		name        string
+		unknown     []string
+		wantProfile map[string][]string
+		wantOthers  []string
+	}{
+		{name: "none", wantProfile: map[string][]string{}},
+		{name: "profile", unknown: []string{"connection[0].host", "connection[0].port"}, wantProfile: map[string][]string{"db": {"connection[0].host", "connection[0].port"}}},
+		{name: "provider", unknown: []string{"host"}, wantProfile: map[string][]string{}, wantOthers: []string{"host"}},
+		{name: "unknown profile name", unknown: []string{"connection[1].name", "connection[1].host"}, wantProfile: map[string][]string{}, wantOthers: []string{"connection[1].name", "connection[1].host"}},
+		{name: "connection list", unknown: []string{"connection"}, wantProfile: map[string][]string{}, wantOthers: []string{"connection"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			byProfile, others := profileAttributes(tt.unknown, profiles)
+			if !reflect.DeepEqual(byProfile, tt.wantProfile) {
+				t.Errorf("profileAttributes() profiles = %v, want %v", byProfile, tt.wantProfile)
+			}
+			if !reflect.DeepEqual(others, tt.wantOthers) {
+				t.Errorf("profileAttributes() others = %v, want %v", others, tt.wantOthers)
+			}
+		})
+	}
+}
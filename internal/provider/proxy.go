@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ProxyModel describes the proxy used to reach the first SSH host.
+type ProxyModel struct {
+	URL      types.String `tfsdk:"url"`
+	Username types.String `tfsdk:"username"`
+	Password types.String `tfsdk:"password"`
+	NoProxy  types.List   `tfsdk:"no_proxy"`
+}
+
+func proxyBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "HTTP CONNECT or SOCKS5 proxy used to reach the first SSH host. Defaults to the `HTTPS_PROXY` or `ALL_PROXY` environment variables.",
+		Attributes: map[string]schema.Attribute{
+			"url": schema.StringAttribute{
+				MarkdownDescription: "Proxy URL with an `http://`, `https://` or `socks5://` scheme.",
+				Optional:            true,
+			},
+			"username": schema.StringAttribute{
+				Optional: true,
+			},
+			"password": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"no_proxy": schema.ListAttribute{
+				ElementType:         types.StringType,
+				MarkdownDescription: "Hosts, domain suffixes and CIDR ranges that are connected to directly.",
+				Optional:            true,
+			},
+		},
+	}
+}
+
+// config returns the proxy settings, falling back to the environment when
+// the block is absent.
+func (m *ProxyModel) config(ctx context.Context) (*remote.ProxyConfig, diag.Diagnostics) {
+	if m == nil || m.URL.IsNull() {
+		return remote.ProxyFromEnvironment(), nil
+	}
+
+	proxy := &remote.ProxyConfig{
+		URL:      m.URL.ValueString(),
+		Username: m.Username.ValueString(),
+		Password: m.Password.ValueString(),
+	}
+	diags := m.NoProxy.ElementsAs(ctx, &proxy.NoProxy, false)
+	return proxy, diags
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// RemoteForwardModel exposes a local service on the host while the
+// resource's commands run.
+type RemoteForwardModel struct {
+	RemoteAddress types.String `tfsdk:"remote_address"`
+	LocalAddress  types.String `tfsdk:"local_address"`
+	Directory     types.String `tfsdk:"directory"`
+	Env           types.String `tfsdk:"env"`
+}
+
+func remoteForwardBlock() schema.ListNestedBlock {
+	return schema.ListNestedBlock{
+		MarkdownDescription: "Listeners opened on the host with `tcpip-forward` while the commands run, the same way as `ssh -R`, e.g. to fetch artifacts from the machine running Terraform on hosts without outbound internet access. Any local user of the host can connect to the listeners while the commands run, so only forward services and files they may access.",
+		NestedObject: schema.NestedBlockObject{
+			Attributes: map[string]schema.Attribute{
+				"remote_address": schema.StringAttribute{
+					MarkdownDescription: "Address the host listens on. Port `0` lets the host pick a free port. Defaults to `127.0.0.1:0`.",
+					Optional:            true,
+				},
+				"local_address": schema.StringAttribute{
+					MarkdownDescription: "Address connections are forwarded to from the machine running Terraform. Conflicts with `directory`.",
+					Optional:            true,
+				},
+				"directory": schema.StringAttribute{
+					MarkdownDescription: "Local directory served read-only over HTTP through the tunnel. A leading `~` is expanded. Directories are not listed and paths with a component starting with `.`, e.g. `.git` or `.env`, are refused. Conflicts with `local_address`.",
+					Optional:            true,
+				},
+				"env": schema.StringAttribute{
+					MarkdownDescription: "Name of an environment variable exported to the commands with the address the host listens on, e.g. `127.0.0.1:41234`, or its URL when `directory` is served, e.g. `http://127.0.0.1:41234`.",
+					Optional:            true,
+				},
+			},
+		},
+	}
+}
+
+func remoteForwards(models []RemoteForwardModel) ([]remote.RemoteForward, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	forwards := make([]remote.RemoteForward, 0, len(models))
+	for i, m := range models {
+		block := path.Root("remote_forward").AtListIndex(i)
+
+		if m.LocalAddress.IsNull() == m.Directory.IsNull() {
+			diags.AddAttributeError(block, "Invalid Remote Forward", "Exactly one of local_address and directory must be set.")
+			continue
+		}
+		if env := m.Env.ValueString(); !m.Env.IsNull() && !environmentNamePattern.MatchString(env) {
+			diags.AddAttributeError(block.AtName("env"), "Invalid Environment Variable",
+				fmt.Sprintf("The name %q is not a valid environment variable name. Names must consist of letters, digits and underscores and not start with a digit.", env))
+			continue
+		}
+
+		forward := remote.RemoteForward{
+			RemoteAddress: m.RemoteAddress.ValueString(),
+			LocalAddress:  m.LocalAddress.ValueString(),
+			Env:           m.Env.ValueString(),
+		}
+		if !m.Directory.IsNull() {
+			forward.Directory = remote.ExpandPath(m.Directory.ValueString())
+		}
+		forwards = append(forwards, forward)
+	}
+	return forwards, diags
+}
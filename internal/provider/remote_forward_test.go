@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRemoteForwards(t *testing.T) {
+	tests := []struct {
+		name      string
+		forward   RemoteForwardModel
+		wantEnv   string
+		wantError string
+	}{
+		{
+			name:    "local address",
+			forward: RemoteForwardModel{LocalAddress: types.StringValue("127.0.0.1:8080"), Env: types.StringValue("PROXY_ADDR")},
+			wantEnv: "PROXY_ADDR",
+		},
+		{
+			name:    "without env",
+			forward: RemoteForwardModel{Directory: types.StringValue("/srv/artifacts"), Env: types.StringNull()},
+		},
+		{
+			name:      "local address and directory",
+			forward:   RemoteForwardModel{LocalAddress: types.StringValue("127.0.0.1:8080"), Directory: types.StringValue("/srv/artifacts")},
+			wantError: "Invalid Remote Forward",
+		},
+		{
+			name:      "neither",
+			forward:   RemoteForwardModel{},
+			wantError: "Invalid Remote Forward",
+		},
+		{
+			name:      "env starting with a digit",
+			forward:   RemoteForwardModel{LocalAddress: types.StringValue("127.0.0.1:8080"), Env: types.StringValue("1ADDR")},
+			wantError: "Invalid Environment Variable",
+		},
+		{
+			name:      "env injecting a command",
+			forward:   RemoteForwardModel{LocalAddress: types.StringValue("127.0.0.1:8080"), Env: types.StringValue("X=1; rm -rf / #")},
+			wantError: "Invalid Environment Variable",
+		},
+		{
+			name:      "empty env",
+			forward:   RemoteForwardModel{LocalAddress: types.StringValue("127.0.0.1:8080"), Env: types.StringValue("")},
+			wantError: "Invalid Environment Variable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := remoteForwards([]RemoteForwardModel{tt.forward})
+			if tt.wantError != "" {
+				if !diags.HasError() || diags.Errors()[0].Summary() != tt.wantError {
+					t.Fatalf("remoteForwards() diagnostics = %v, want %q", diags, tt.wantError)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("remoteForwards() diagnostics = %v", diags)
+			}
+			if len(got) != 1 || got[0].Env != tt.wantEnv {
+				t.Errorf("remoteForwards() = %+v, want env %q", got, tt.wantEnv)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// RetryModel decides which failed operations of a resource are retried.
+type RetryModel struct {
+	MaxAttempts types.Int64    `tfsdk:"max_attempts"`
+	MaxDelay    types.String   `tfsdk:"max_delay"`
+	RetryOn     []types.String `tfsdk:"retry_on"`
+}
+
+func retryBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "Retries of failed commands and file copies, with exponential backoff and jitter starting at `retry_delay`. " +
+			"By default, connection failures are retried up to 5 times and commands that ran but failed are not, so that commands that are not idempotent do not run twice.",
+		Attributes: map[string]schema.Attribute{
+			"max_attempts": schema.Int64Attribute{
+				MarkdownDescription: "Number of times an operation is attempted. Defaults to `5`.",
+				Optional:            true,
+			},
+			"max_delay": schema.StringAttribute{
+				MarkdownDescription: "Longest delay between attempts. Defaults to `1m`.",
+				Optional:            true,
+			},
+			"retry_on": schema.ListAttribute{
+				ElementType: types.StringType,
+				MarkdownDescription: "Kinds of errors that are retried: `dial` when the host, a jump host or the proxy cannot be reached, `host_key` when the host key cannot be verified, " +
+					"`auth` when authentication fails, `session` when a session cannot be opened, `disconnect` when the connection is lost while a command runs, `timeout` when a command times out, and `command` when a command exits with an unexpected status or its output does not meet the `exec` expectations. " +
+					"Defaults to `[\"dial\", \"session\"]`.",
+				Optional: true,
+			},
+		},
+	}
+}
+
+// policy returns the retry policy of the resource, whose retries start after
+// delay.
+func (m *RetryModel) policy(delay time.Duration) (remote.RetryPolicy, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	policy := remote.RetryPolicy{Delay: delay}
+	if m == nil {
+		return policy, diags
+	}
+	root := path.Root("retry")
+
+	if !m.MaxAttempts.IsNull() {
+		if m.MaxAttempts.ValueInt64() < 1 {
+			diags.AddAttributeError(root.AtName("max_attempts"), "Invalid Retry Attempts", "The number of attempts must be at least 1.")
+		}
+		policy.MaxAttempts = int(m.MaxAttempts.ValueInt64())
+	}
+
+	if !m.MaxDelay.IsNull() {
+		maxDelay, err := time.ParseDuration(m.MaxDelay.ValueString())
+		if err != nil {
+			diags.AddAttributeError(root.AtName("max_delay"), "Invalid Duration", "The provider cannot parse the duration: "+err.Error())
+		}
+		policy.MaxDelay = maxDelay
+	}
+
+	if m.RetryOn != nil {
+		policy.RetryOn = make([]remote.ErrorKind, 0, len(m.RetryOn))
+		for i, kind := range m.RetryOn {
+			if !validErrorKind(kind.ValueString()) {
+				diags.AddAttributeError(root.AtName("retry_on").AtListIndex(i), "Invalid Error Kind",
+					fmt.Sprintf("The error kind %q is not one of %s.", kind.ValueString(), errorKindNames()))
+				continue
+			}
+			policy.RetryOn = append(policy.RetryOn, remote.ErrorKind(kind.ValueString()))
+		}
+	}
+	return policy, diags
+}
+
+func validErrorKind(kind string) bool {
+	for _, k := range remote.ErrorKinds {
+		if string(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func errorKindNames() string {
+	names := make([]string, 0, len(remote.ErrorKinds))
+	for _, k := range remote.ErrorKinds {
+		names = append(names, fmt.Sprintf("%q", k))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ConnectionModel overrides the provider connection for a single resource.
+type ConnectionModel struct {
+	Host       types.String `tfsdk:"host"`
+	Port       types.String `tfsdk:"port"`
+	User       types.String `tfsdk:"user"`
+	Password   types.String `tfsdk:"password"`
+	PrivateKey types.String `tfsdk:"private_key"`
+	Agent      types.Bool   `tfsdk:"agent"`
+	Timeout    types.String `tfsdk:"timeout"`
+
+	KnownHosts          types.String `tfsdk:"known_hosts"`
+	KnownHostsFiles     types.List   `tfsdk:"known_hosts_files"`
+	HostKeyFingerprints types.List   `tfsdk:"host_key_fingerprints"`
+}
+
+func connectionBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "Connection settings for this resource, merged over the provider configuration or the selected `connection_profile`.",
+		Attributes: map[string]schema.Attribute{
+			"host": schema.StringAttribute{
+				Optional: true,
+			},
+			"port": schema.StringAttribute{
+				Optional: true,
+			},
+			"user": schema.StringAttribute{
+				Optional: true,
+			},
+			"password": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"private_key": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"agent": schema.BoolAttribute{
+				Optional: true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout for establishing the SSH connection and those to the jump hosts, e.g. `30s`.",
+				Optional:            true,
+			},
+			"known_hosts": schema.StringAttribute{
+				MarkdownDescription: "Content in `known_hosts` format used to verify the host key. Setting any of `known_hosts`, `known_hosts_files` and `host_key_fingerprints` replaces the host key settings of the provider or connection profile.",
+				Optional:            true,
+			},
+			"known_hosts_files": schema.ListAttribute{
+				MarkdownDescription: "Paths to `known_hosts` files used to verify the host key.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"host_key_fingerprints": schema.ListAttribute{
+				MarkdownDescription: "SHA256 fingerprints of the accepted host keys. The `host_key_fingerprints` of the provider or connection profile do not apply when `host` names another host.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+		},
+	}
+}
+
+// apply merges the connection settings that are set over c.
+func (m *ConnectionModel) apply(ctx context.Context, c *remote.Config) diag.Diagnostics {
+	var diags diag.Diagnostics
+	root := path.Root("connection")
+
+	pinned := false
+	if !m.Host.IsNull() && m.Host.ValueString() != c.Host {
+		c.Host = m.Host.ValueString()
+		// The port and the pinned host keys belong to the host they were
+		// configured for.
+		c.Port = ""
+		pinned = len(c.HostKeys.Fingerprints) > 0
+		c.HostKeys.Fingerprints = nil
+	}
+	if !m.KnownHosts.IsNull() || !m.KnownHostsFiles.IsNull() || !m.HostKeyFingerprints.IsNull() {
+		hostKeys, d := hostKeyConfig(ctx, m.KnownHosts, m.KnownHostsFiles, m.HostKeyFingerprints)
+		diags.Append(d...)
+		c.HostKeys = hostKeys
+	} else if pinned && c.HostKeys.IsEmpty() {
+		diags.AddAttributeError(root.AtName("host_key_fingerprints"), "Missing Host Key Verification",
+			"The host_key_fingerprints of the provider or connection profile pin the keys of its own host, not of "+c.Host+". "+
+				"Set known_hosts, known_hosts_files or host_key_fingerprints in the connection block.")
+		return diags
+	}
+	if !m.Port.IsNull() {
+		c.Port = m.Port.ValueString()
+	}
+	if !m.User.IsNull() {
+		c.User = m.User.ValueString()
+	}
+	if !m.Password.IsNull() {
+		c.Password = m.Password.ValueString()
+	}
+	if !m.PrivateKey.IsNull() {
+		if _, err := remote.ParsePrivateKey([]byte(m.PrivateKey.ValueString()), c.Passphrase); err != nil {
+			diags.AddAttributeError(root.AtName("private_key"), "Invalid Private Key", "The provider cannot parse the private key: "+err.Error())
+			return diags
+		}
+		c.PrivateKey = m.PrivateKey.ValueString()
+		// The provider certificate certifies the provider key, not this one.
+		c.Certificate = ""
+	}
+	if !m.Agent.IsNull() {
+		c.Agent = m.Agent.ValueBool()
+	}
+	if !m.Timeout.IsNull() {
+		timeout, err := time.ParseDuration(m.Timeout.ValueString())
+		if err != nil {
+			diags.AddAttributeError(root.AtName("timeout"), "Invalid Connection Timeout", err.Error())
+			return diags
+		}
+		c.Timeout = timeout
+		for i, hop := range c.JumpHosts {
+			// Hops are shared with the settings c was cloned from.
+			hop = hop.Clone()
+			hop.Timeout = timeout
+			c.JumpHosts[i] = hop
+		}
+	}
+	return diags
+}
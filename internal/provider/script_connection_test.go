@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestConnectionModel_apply(t *testing.T) {
+	key := testPrivateKey(t)
+
+	pinned := func(c *remote.Config) {
+		c.HostKeys = remote.HostKeyConfig{Fingerprints: []string{testFingerprint}}
+	}
+	bastion := &remote.Config{Host: "bastion.example.com", Timeout: 20 * time.Second}
+
+	tests := []struct {
+		name       string
+		setup      func(c *remote.Config)
+		connection ConnectionModel
+		want       func(c *remote.Config) bool
+		wantError  string
+	}{
+		{
+			name:       "nothing set",
+			connection: ConnectionModel{},
+			want: func(c *remote.Config) bool {
+				return c.Host == "provider.example.com" && c.Port == "2222" && c.User == "deploy" && c.Certificate != ""
+			},
+		},
+		{
+			name:       "new host resets the port",
+			connection: ConnectionModel{Host: types.StringValue("web.example.com")},
+			want:       func(c *remote.Config) bool { return c.Host == "web.example.com" && c.Port == "" },
+		},
+		{
+			name:       "same host keeps the port",
+			connection: ConnectionModel{Host: types.StringValue("provider.example.com")},
+			want:       func(c *remote.Config) bool { return c.Port == "2222" },
+		},
+		{
+			name:       "new host and port",
+			connection: ConnectionModel{Host: types.StringValue("web.example.com"), Port: types.StringValue("22")},
+			want:       func(c *remote.Config) bool { return c.Host == "web.example.com" && c.Port == "22" },
+		},
+		{
+			name:       "credentials",
+			connection: ConnectionModel{User: types.StringValue("root"), Password: types.StringValue("hunter2"), Agent: types.BoolValue(false)},
+			want:       func(c *remote.Config) bool { return c.User == "root" && c.Password == "hunter2" && !c.Agent },
+		},
+		{
+			name:       "private key clears the certificate",
+			connection: ConnectionModel{PrivateKey: types.StringValue(key)},
+			want:       func(c *remote.Config) bool { return c.PrivateKey == key && c.Certificate == "" },
+		},
+		{
+			name:       "timeout",
+			connection: ConnectionModel{Timeout: types.StringValue("45s")},
+			want:       func(c *remote.Config) bool { return c.Timeout == 45*time.Second },
+		},
+		{
+			name:       "timeout applies to the jump hosts",
+			setup:      func(c *remote.Config) { c.JumpHosts = []*remote.Config{bastion} },
+			connection: ConnectionModel{Timeout: types.StringValue("45s")},
+			want: func(c *remote.Config) bool {
+				return c.JumpHosts[0].Timeout == 45*time.Second && bastion.Timeout == 20*time.Second
+			},
+		},
+		{
+			name:       "same host keeps pinned host keys",
+			setup:      pinned,
+			connection: ConnectionModel{User: types.StringValue("root")},
+			want:       func(c *remote.Config) bool { return len(c.HostKeys.Fingerprints) == 1 },
+		},
+		{
+			name: "new host keeps known_hosts files",
+			setup: func(c *remote.Config) {
+				c.HostKeys = remote.HostKeyConfig{KnownHostsFiles: []string{"~/.ssh/known_hosts"}, Fingerprints: []string{testFingerprint}}
+			},
+			connection: ConnectionModel{Host: types.StringValue("web.example.com")},
+			want: func(c *remote.Config) bool {
+				return len(c.HostKeys.KnownHostsFiles) == 1 && len(c.HostKeys.Fingerprints) == 0
+			},
+		},
+		{
+			name:  "new host with its own host keys",
+			setup: pinned,
+			connection: ConnectionModel{
+				Host:                types.StringValue("web.example.com"),
+				KnownHostsFiles:     types.ListNull(types.StringType),
+				HostKeyFingerprints: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("SHA256:web")}),
+			},
+			want: func(c *remote.Config) bool {
+				return len(c.HostKeys.Fingerprints) == 1 && c.HostKeys.Fingerprints[0] == "SHA256:web"
+			},
+		},
+		{
+			name:       "new host without host keys",
+			setup:      pinned,
+			connection: ConnectionModel{Host: types.StringValue("web.example.com")},
+			wantError:  "Missing Host Key Verification",
+		},
+		{
+			name:       "invalid private key",
+			connection: ConnectionModel{PrivateKey: types.StringValue("not a key")},
+			wantError:  "Invalid Private Key",
+		},
+		{
+			name:       "invalid timeout",
+			connection: ConnectionModel{Timeout: types.StringValue("soon")},
+			wantError:  "Invalid Connection Timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testDefaults()
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			diags := tt.connection.apply(context.Background(), c)
+			if tt.wantError != "" {
+				if !diags.HasError() || diags.Errors()[0].Summary() != tt.wantError {
+					t.Fatalf("apply() diagnostics = %v, want %q", diags, tt.wantError)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("apply() diagnostics = %v", diags)
+			}
+			if !tt.want(c) {
+				t.Errorf("apply() = %+v", c)
+			}
+		})
+	}
+}
@@ -2,18 +2,31 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/appkins/terraform-provider-ssh/internal/log"
 	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
+// Lifecycles of exec blocks.
+const (
+	lifecycleCreate  = "create"
+	lifecycleRead    = "read"
+	lifecycleUpdate  = "update"
+	lifecycleDestroy = "destroy"
+)
+
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &ScriptResource{}
 var _ resource.ResourceWithImportState = &ScriptResource{}
@@ -24,7 +37,7 @@ func NewScriptResource() resource.Resource {
 
 // ScriptResource defines the resource implementation.
 type ScriptResource struct {
-	client *remote.Provisioner
+	provider *providerData
 }
 
 // ScriptResourceModel describes the resource data model.
@@ -36,8 +49,17 @@ type ScriptResourceModel struct {
 	//Query      types.Set    `tfsdk:"query"`
 	//Script     types.Set    `tfsdk:"script"`
 	Exec []struct {
-		Commands  []types.String `tfsdk:"commands"`
-		Lifecycle types.String   `tfsdk:"lifecycle"`
+		Commands             []types.String `tfsdk:"commands"`
+		Script               types.String   `tfsdk:"script"`
+		Interpreter          []types.String `tfsdk:"interpreter"`
+		Lifecycle            types.String   `tfsdk:"lifecycle"`
+		ForwardAgent         types.Bool     `tfsdk:"forward_agent"`
+		ExpectedExitCodes    []types.Int64  `tfsdk:"expected_exit_codes"`
+		StdoutMustMatch      types.String   `tfsdk:"stdout_must_match"`
+		StderrMustNotMatch   types.String   `tfsdk:"stderr_must_not_match"`
+		ContinueOnError      types.Bool     `tfsdk:"continue_on_error"`
+		Environment          types.Map      `tfsdk:"environment"`
+		SensitiveEnvironment types.Map      `tfsdk:"sensitive_environment"`
 	} `tfsdk:"exec"`
 	File []struct {
 		Source      types.String `tfsdk:"source"`
@@ -47,7 +69,16 @@ type ScriptResourceModel struct {
 		Owner       types.String `tfsdk:"owner"`
 		Group       types.String `tfsdk:"group"`
 	} `tfsdk:"file"`
-	Result types.String `tfsdk:"result"`
+	Result            types.String     `tfsdk:"result"`
+	Results           types.List       `tfsdk:"results"`
+	ConnectionProfile types.String     `tfsdk:"connection_profile"`
+	Connection        *ConnectionModel `tfsdk:"connection"`
+
+	RemoteForward []RemoteForwardModel `tfsdk:"remote_forward"`
+	Retry         *RetryModel          `tfsdk:"retry"`
+
+	HostKeyFingerprint types.String `tfsdk:"host_key_fingerprint"`
+	HostKeyRotation    types.String `tfsdk:"host_key_rotation"`
 }
 
 func (r *ScriptResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -66,23 +97,41 @@ func (r *ScriptResource) Schema(ctx context.Context, req resource.SchemaRequest,
 				Optional:            true,
 			},
 			"timeout": schema.StringAttribute{
-				MarkdownDescription: "Timeout for the SSH connection.",
+				MarkdownDescription: "Time after which a running command is killed, e.g. `30m`. Defaults to the provider `command_timeout`; `0s` disables the limit.",
 				Optional:            true,
-				Computed:            true,
-				Default:             stringdefault.StaticString("5m"),
 			},
 			"retry_delay": schema.StringAttribute{
-				MarkdownDescription: "Delay before retrying the SSH connection.",
+				MarkdownDescription: "Delay before the first retry of a failed operation, doubled after every attempt. See `retry`.",
 				Optional:            true,
 				Computed:            true,
 				Default:             stringdefault.StaticString("10s"),
 			},
 			"result": schema.StringAttribute{
-				Computed:  true,
-				Sensitive: true,
+				MarkdownDescription: "Stdout of the last command run by the last create, update or refresh that ran any.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"results": resultsAttribute(),
+			"host_key_fingerprint": schema.StringAttribute{
+				MarkdownDescription: "SHA256 fingerprint of the host key trusted on first use when the provider `host_key_policy` is `tofu`. Later operations refuse to connect when the host presents a different key, and destroy commands are not run while no fingerprint is recorded.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					hostKeyFingerprintModifier{},
+				},
+			},
+			"host_key_rotation": schema.StringAttribute{
+				MarkdownDescription: "Arbitrary value that, when changed, trusts the host key presented during the next update and records its fingerprint in `host_key_fingerprint`. Read commands keep verifying the recorded key until then, so plan with `-refresh=false` if they fail.",
+				Optional:            true,
+			},
+			"connection_profile": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `connection` profile to run the script on. Defaults to the provider `host`.",
+				Optional:            true,
 			},
 		},
 		Blocks: map[string]schema.Block{
+			"connection":     connectionBlock(),
+			"remote_forward": remoteForwardBlock(),
+			"retry":          retryBlock(),
 			"file": schema.SetNestedBlock{
 				MarkdownDescription: "Files.",
 				NestedObject: schema.NestedBlockObject{
@@ -116,13 +165,54 @@ func (r *ScriptResource) Schema(ctx context.Context, req resource.SchemaRequest,
 					Attributes: map[string]schema.Attribute{
 						"commands": schema.SetAttribute{
 							ElementType:         types.StringType,
-							MarkdownDescription: "List of commands to run.",
-							Required:            true,
+							MarkdownDescription: "List of commands to run through the login shell of the user. Conflicts with `script`.",
+							Optional:            true,
+						},
+						"script": schema.StringAttribute{
+							MarkdownDescription: "Multi-line script uploaded to a private temporary directory on the host and run with `interpreter`, so that it behaves the same whatever the login shell of the user. Conflicts with `commands`.",
+							Optional:            true,
+						},
+						"interpreter": schema.ListAttribute{
+							ElementType:         types.StringType,
+							MarkdownDescription: "Program and arguments that run `script`, which is passed as the last argument, e.g. `[\"/bin/bash\", \"-euo\", \"pipefail\"]` or `[\"python3\"]`. Defaults to `[\"/bin/sh\"]`.",
+							Optional:            true,
 						},
 						"lifecycle": schema.StringAttribute{
-							MarkdownDescription: "Lifecycle of the command. Valid values are `create`, `read`, `update` and `destroy`.",
+							MarkdownDescription: "Lifecycle of the command. Valid values are `create`, `read`, `update` and `destroy`. Defaults to `create`.",
 							Optional:            true,
 						},
+						"forward_agent": schema.BoolAttribute{
+							MarkdownDescription: "Forward an SSH agent to the commands, so that they can authenticate onwards, e.g. to clone private git repositories. The local agent is forwarded when the connection uses `agent`, otherwise an in-memory agent holding `private_key`.",
+							Optional:            true,
+						},
+						"expected_exit_codes": schema.ListAttribute{
+							ElementType:         types.Int64Type,
+							MarkdownDescription: "Exit codes that count as success. Defaults to `[0]`.",
+							Optional:            true,
+						},
+						"stdout_must_match": schema.StringAttribute{
+							MarkdownDescription: "Regular expression the stdout of each command must match, e.g. `(?m)^active$`.",
+							Optional:            true,
+						},
+						"stderr_must_not_match": schema.StringAttribute{
+							MarkdownDescription: "Regular expression the stderr of each command must not match, e.g. `(?i)warning`.",
+							Optional:            true,
+						},
+						"continue_on_error": schema.BoolAttribute{
+							MarkdownDescription: "Run the following commands even when one of these fails or times out, for best-effort steps. Failures are recorded in `results`.",
+							Optional:            true,
+						},
+						"environment": schema.MapAttribute{
+							ElementType:         types.StringType,
+							MarkdownDescription: "Environment variables set for the commands, over those of the provider. Variables the server refuses to set, e.g. because of `AcceptEnv` in `sshd_config`, are set with `env` on the command line instead, which runs the command with `sh`.",
+							Optional:            true,
+						},
+						"sensitive_environment": schema.MapAttribute{
+							ElementType:         types.StringType,
+							MarkdownDescription: "Like `environment`, for secrets: their values are masked in the provider logs, and those the server refuses to set are passed over stdin rather than on the command line.",
+							Optional:            true,
+							Sensitive:           true,
+						},
 					},
 				},
 			},
@@ -136,40 +226,30 @@ func (r *ScriptResource) Configure(ctx context.Context, req resource.ConfigureRe
 		return
 	}
 
-	if client, ok := req.ProviderData.(*remote.Provisioner); !ok {
+	if data, ok := req.ProviderData.(*providerData); !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 	} else {
-		r.client = client
+		r.provider = data
 	}
 }
 
 func (r *ScriptResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var saved *ScriptResourceModel
 	var data *ScriptResourceModel
 
 	// Read Terraform plan data into the model
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &saved)...)
-
-	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	scripts := make([]string, 0)
+	scripts, diags := data.commands(ctx, lifecycleCreate)
+	resp.Diagnostics.Append(diags...)
 	files := make([]remote.File, 0)
 
-	for _, e := range data.Exec {
-		if e.Lifecycle.String() == "create" {
-			for _, c := range e.Commands {
-				scripts = append(scripts, c.String())
-			}
-		}
-	}
-
 	for _, f := range data.File {
 		files = append(files, remote.File{
 			Source:      f.Source,
@@ -181,10 +261,35 @@ func (r *ScriptResource) Create(ctx context.Context, req resource.CreateRequest,
 		})
 	}
 
-	r.client.CopyFiles(files, ctx)
-	r.client.Execute(scripts, ctx)
+	forwards, diags := remoteForwards(data.RemoteForward)
+	resp.Diagnostics.Append(diags...)
 
-	data.Result = types.StringValue("script-id")
+	client := r.client(ctx, data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, fingerprint, err := r.pinHostKey(ctx, client, types.StringNull(), true)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "verify host key", err)
+		return
+	}
+	data.HostKeyFingerprint = fingerprint
+
+	if err := client.CopyFiles(files, ctx); err != nil {
+		addClientError(&resp.Diagnostics, "copy files", err)
+		return
+	}
+
+	results, err := r.execute(ctx, client, forwards, scripts)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "create script", err)
+		return
+	}
+
+	data.Result = lastStdout(results)
+	data.Results, diags = resultsValue(ctx, results)
+	resp.Diagnostics.Append(diags...)
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
@@ -204,20 +309,39 @@ func (r *ScriptResource) Read(ctx context.Context, req resource.ReadRequest, res
 		return
 	}
 
-	scripts := make([]string, 0)
+	scripts, diags := data.commands(ctx, lifecycleRead)
+	resp.Diagnostics.Append(diags...)
 
-	for _, e := range data.Exec {
-		if e.Lifecycle.String() == "read" {
-			for _, c := range e.Commands {
-				scripts = append(scripts, c.String())
-			}
+	forwards, diags := remoteForwards(data.RemoteForward)
+	resp.Diagnostics.Append(diags...)
+
+	client := r.client(ctx, data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var results []remote.Result
+	var err error
+	if len(scripts) > 0 {
+		var fingerprint types.String
+		client, fingerprint, err = r.pinHostKey(ctx, client, data.HostKeyFingerprint, false)
+		if err == nil {
+			data.HostKeyFingerprint = fingerprint
+			results, err = r.execute(ctx, client, forwards, scripts)
 		}
 	}
 
-	if out, err := r.client.Execute(scripts, ctx); err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to read script, got error: %s", err))
-	} else {
-		data.Result = types.StringValue(out)
+	var unresolved *remote.UnresolvedError
+	if errors.As(err, &unresolved) {
+		// Refresh runs during plan, before values the provider depends on
+		// are known, so keep the prior state rather than failing the plan.
+		tflog.Warn(ctx, "Skipping read commands: "+err.Error())
+	} else if err != nil {
+		addClientError(&resp.Diagnostics, "read script", err)
+	} else if len(scripts) > 0 {
+		data.Result = lastStdout(results)
+		data.Results, diags = resultsValue(ctx, results)
+		resp.Diagnostics.Append(diags...)
 	}
 
 	// Save updated data into Terraform state
@@ -226,27 +350,57 @@ func (r *ScriptResource) Read(ctx context.Context, req resource.ReadRequest, res
 
 func (r *ScriptResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
 	var data *ScriptResourceModel
+	var state *ScriptResourceModel
 
 	// Read Terraform plan data into the model
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	scripts := make([]string, 0)
-	for _, e := range data.Exec {
-		if e.Lifecycle.String() == "update" {
-			for _, c := range e.Commands {
-				scripts = append(scripts, c.String())
-			}
+	scripts, diags := data.commands(ctx, lifecycleUpdate)
+	resp.Diagnostics.Append(diags...)
+
+	forwards, diags := remoteForwards(data.RemoteForward)
+	resp.Diagnostics.Append(diags...)
+
+	client := r.client(ctx, data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Changes that run nothing, e.g. to triggers or timeouts, must not
+	// require the host to be reachable, so the host key is only scanned
+	// when there are commands to run or a rotation was requested.
+	data.HostKeyFingerprint = state.HostKeyFingerprint
+	rotate := !data.HostKeyRotation.Equal(state.HostKeyRotation)
+	if len(scripts) > 0 || rotate {
+		var fingerprint types.String
+		var err error
+		client, fingerprint, err = r.pinHostKey(ctx, client, state.HostKeyFingerprint, rotate)
+		if err != nil {
+			addClientError(&resp.Diagnostics, "verify host key", err)
+			return
 		}
+		data.HostKeyFingerprint = fingerprint
 	}
 
-	if out, err := r.client.Execute(scripts, ctx); err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to read script, got error: %s", err))
-	} else {
-		log.Info(ctx, "Script output: %s", out)
+	data.Result = state.Result
+	data.Results = state.Results
+	if len(scripts) > 0 {
+		results, err := r.execute(ctx, client, forwards, scripts)
+		if err != nil {
+			addClientError(&resp.Diagnostics, "update script", err)
+			return
+		}
+		log.Info(ctx, "Ran %d update commands", len(results))
+
+		data.Result = lastStdout(results)
+		data.Results, diags = resultsValue(ctx, results)
+		resp.Diagnostics.Append(diags...)
 	}
 
 	// Save updated data into Terraform state
@@ -263,20 +417,218 @@ func (r *ScriptResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	scripts := make([]string, 0)
-	for _, e := range data.Exec {
-		if e.Lifecycle.String() == "destroy" {
-			for _, c := range e.Commands {
-				scripts = append(scripts, c.String())
+	scripts, diags := data.commands(ctx, lifecycleDestroy)
+	resp.Diagnostics.Append(diags...)
+
+	forwards, diags := remoteForwards(data.RemoteForward)
+	resp.Diagnostics.Append(diags...)
+
+	client := r.client(ctx, data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() || len(scripts) == 0 {
+		return
+	}
+
+	resp.Diagnostics.Append(r.provider.requireHostKey(data.HostKeyFingerprint, "run the destroy commands")...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, _, err := r.pinHostKey(ctx, client, data.HostKeyFingerprint, false)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "verify host key", err)
+		return
+	}
+
+	if results, err := r.execute(ctx, client, forwards, scripts); err != nil {
+		addClientError(&resp.Diagnostics, "delete script", err)
+	} else {
+		log.Info(ctx, "Ran %d destroy commands", len(results))
+	}
+}
+
+// commands returns the commands of the exec blocks with the given lifecycle.
+// Blocks without a lifecycle run on create. The output patterns of every
+// block are checked, so that mistakes surface before the destroy commands
+// are needed.
+func (m *ScriptResourceModel) commands(ctx context.Context, lifecycle string) ([]remote.Command, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	commands := make([]remote.Command, 0)
+	for _, e := range m.Exec {
+		stdoutMustMatch, err := compilePattern(e.StdoutMustMatch)
+		if err != nil {
+			diags.AddAttributeError(path.Root("exec"), "Invalid Output Pattern", "The stdout_must_match pattern is invalid: "+err.Error())
+		}
+		stderrMustNotMatch, err := compilePattern(e.StderrMustNotMatch)
+		if err != nil {
+			diags.AddAttributeError(path.Root("exec"), "Invalid Output Pattern", "The stderr_must_not_match pattern is invalid: "+err.Error())
+		}
+		env, d := environment(ctx, e.Environment, path.Root("exec"))
+		diags.Append(d...)
+		sensitiveEnv, d := environment(ctx, e.SensitiveEnvironment, path.Root("exec"))
+		diags.Append(d...)
+		switch {
+		case e.Commands == nil && e.Script.IsNull():
+			diags.AddAttributeError(path.Root("exec"), "Missing Commands", "Each exec block must set either commands or script.")
+		case e.Commands != nil && !e.Script.IsNull():
+			diags.AddAttributeError(path.Root("exec"), "Conflicting Commands", "An exec block cannot set both commands and script.")
+		case e.Interpreter != nil && e.Script.IsNull():
+			diags.AddAttributeError(path.Root("exec"), "Interpreter Without Script", "The interpreter only runs a script; set script or remove interpreter.")
+		}
+
+		l := e.Lifecycle.ValueString()
+		if l == "" {
+			l = lifecycleCreate
+		}
+		if l != lifecycle {
+			continue
+		}
+
+		expected := make([]int, 0, len(e.ExpectedExitCodes))
+		for _, code := range e.ExpectedExitCodes {
+			expected = append(expected, int(code.ValueInt64()))
+		}
+		cmds := make([]remote.Command, 0, len(e.Commands))
+		for _, c := range e.Commands {
+			cmds = append(cmds, remote.Command{Command: c.ValueString()})
+		}
+		if !e.Script.IsNull() {
+			interpreter := make([]string, 0, len(e.Interpreter))
+			for _, arg := range e.Interpreter {
+				interpreter = append(interpreter, arg.ValueString())
 			}
+			cmds = append(cmds, remote.Command{Script: e.Script.ValueString(), Interpreter: interpreter})
+		}
+		for _, c := range cmds {
+			commands = append(commands, remote.Command{
+				Command:            c.Command,
+				Script:             c.Script,
+				Interpreter:        c.Interpreter,
+				ForwardAgent:       e.ForwardAgent.ValueBool(),
+				ExpectedExitCodes:  expected,
+				StdoutMustMatch:    stdoutMustMatch,
+				StderrMustNotMatch: stderrMustNotMatch,
+				ContinueOnError:    e.ContinueOnError.ValueBool(),
+
+				Environment:          env,
+				SensitiveEnvironment: sensitiveEnv,
+			})
 		}
 	}
+	return commands, diags
+}
 
-	if out, err := r.client.Execute(scripts, ctx); err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete script, got error: %s", err))
-	} else {
-		log.Info(ctx, "Script output: %s", out)
+// compilePattern compiles an optional regular expression.
+func compilePattern(pattern types.String) (*regexp.Regexp, error) {
+	if pattern.IsNull() {
+		return nil, nil
+	}
+	return regexp.Compile(pattern.ValueString())
+}
+
+// client returns the provisioner for the connection profile selected by
+// data, with its connection block merged over and its retry policy.
+func (r *ScriptResource) client(ctx context.Context, data *ScriptResourceModel, diags *diag.Diagnostics) *remote.Provisioner {
+	retryDelay := 10 * time.Second
+	if data.RetryDelay.ValueString() != "" {
+		var err error
+		if retryDelay, err = time.ParseDuration(data.RetryDelay.ValueString()); err != nil {
+			diags.AddAttributeError(path.Root("retry_delay"), "Invalid Duration", "The provider cannot parse the duration: "+err.Error())
+			return nil
+		}
+	}
+	policy, d := data.Retry.policy(retryDelay)
+	diags.Append(d...)
+
+	client, d := r.provider.connection(ctx, data.ConnectionProfile.ValueString(), data.Connection)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil
+	}
+	client = client.WithRetry(policy)
+	if data.Timeout.ValueString() != "" {
+		var err error
+		if client.Timeout, err = time.ParseDuration(data.Timeout.ValueString()); err != nil {
+			diags.AddAttributeError(path.Root("timeout"), "Invalid Duration", "The provider cannot parse the duration: "+err.Error())
+			return nil
+		}
+	}
+	return client
+}
+
+// execute runs scripts while the remote forwards are open, exporting their
+// addresses to every command.
+func (r *ScriptResource) execute(ctx context.Context, client *remote.Provisioner, forwards []remote.RemoteForward, scripts []remote.Command) ([]remote.Result, error) {
+	if len(scripts) == 0 {
+		return nil, nil
+	}
+
+	forwarding, err := client.Forward(forwards, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer forwarding.Close()
+
+	for i := range scripts {
+		env := make(map[string]string, len(scripts[i].Environment)+len(forwarding.Environment))
+		for name, value := range scripts[i].Environment {
+			env[name] = value
+		}
+		for name, value := range forwarding.Environment {
+			env[name] = value
+		}
+		scripts[i].Environment = env
+	}
+	return client.Execute(scripts, ctx)
+}
+
+// pinHostKey enforces host_key_policy = "tofu". It returns client pinned to
+// the recorded fingerprint, or to the key the host presents now when nothing
+// was recorded yet or a rotation was requested, with the fingerprint to record.
+func (r *ScriptResource) pinHostKey(ctx context.Context, client *remote.Provisioner, recorded types.String, rotate bool) (*remote.Provisioner, types.String, error) {
+	if !r.provider.tofu {
+		return client, types.StringNull(), nil
+	}
+
+	fingerprint := recorded.ValueString()
+	if fingerprint == "" || rotate {
+		var err error
+		if fingerprint, err = client.ScanHostKey(ctx); err != nil {
+			return nil, recorded, err
+		}
+		tflog.Info(ctx, "Trusting host key on first use", map[string]interface{}{"fingerprint": fingerprint})
+	}
+
+	pinned, err := client.PinHostKey(ctx, fingerprint)
+	if err != nil {
+		return nil, recorded, err
+	}
+	return pinned, types.StringValue(fingerprint), nil
+}
+
+// addClientError reports a failed remote operation, explaining when it failed
+// because the provider configuration was never resolved, the host key could
+// not be verified or a command failed.
+func addClientError(diags *diag.Diagnostics, action string, err error) {
+	var unresolved *remote.UnresolvedError
+	if errors.As(err, &unresolved) {
+		diags.AddError("Unresolved Provider Configuration", fmt.Sprintf("Unable to %s, %s.", action, err))
+		return
+	}
+	var hostKeyErr *remote.HostKeyError
+	if errors.As(err, &hostKeyErr) {
+		diags.AddError("Host Key Verification Failed", fmt.Sprintf("Unable to %s, %s. "+
+			"If the host key changed legitimately, update known_hosts or host_key_fingerprints, "+
+			"or change host_key_rotation when the provider host_key_policy is \"tofu\".", action, err))
+		return
+	}
+	var cmdErr *remote.CommandError
+	if errors.As(err, &cmdErr) {
+		diags.AddError("Command Failed", fmt.Sprintf("Unable to %s, %s.\n\nstdout:\n%s\n\nstderr:\n%s",
+			action, cmdErr, outputTail(cmdErr.Result.Stdout), outputTail(cmdErr.Result.Stderr)))
+		return
 	}
+	diags.AddError("Client Error", fmt.Sprintf("Unable to %s, got error: %s", action, err))
 }
 
 func (r *ScriptResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
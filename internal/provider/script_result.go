@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ResultModel describes a command run by the resource.
+type ResultModel struct {
+	Command    types.String `tfsdk:"command"`
+	Stdout     types.String `tfsdk:"stdout"`
+	Stderr     types.String `tfsdk:"stderr"`
+	ExitCode   types.Int64  `tfsdk:"exit_code"`
+	ExitSignal types.String `tfsdk:"exit_signal"`
+	StartTime  types.String `tfsdk:"start_time"`
+	Duration   types.String `tfsdk:"duration"`
+}
+
+var resultAttrTypes = map[string]attr.Type{
+	"command":     types.StringType,
+	"stdout":      types.StringType,
+	"stderr":      types.StringType,
+	"exit_code":   types.Int64Type,
+	"exit_signal": types.StringType,
+	"start_time":  types.StringType,
+	"duration":    types.StringType,
+}
+
+func resultsAttribute() schema.ListNestedAttribute {
+	return schema.ListNestedAttribute{
+		MarkdownDescription: "Results of the commands run by the last create, update or refresh that ran any, in the order they ran.",
+		Computed:            true,
+		Sensitive:           true,
+		NestedObject: schema.NestedAttributeObject{
+			Attributes: map[string]schema.Attribute{
+				"command": schema.StringAttribute{
+					Computed: true,
+				},
+				"stdout": schema.StringAttribute{
+					Computed: true,
+				},
+				"stderr": schema.StringAttribute{
+					Computed: true,
+				},
+				"exit_code": schema.Int64Attribute{
+					MarkdownDescription: "Exit status of the command, or `-1` when it did not report one, e.g. because it timed out.",
+					Computed:            true,
+				},
+				"exit_signal": schema.StringAttribute{
+					MarkdownDescription: "Signal that terminated the command, e.g. `KILL`.",
+					Computed:            true,
+				},
+				"start_time": schema.StringAttribute{
+					MarkdownDescription: "Time the command started, in RFC 3339 format.",
+					Computed:            true,
+				},
+				"duration": schema.StringAttribute{
+					MarkdownDescription: "Time the command took, e.g. `1.5s`.",
+					Computed:            true,
+				},
+			},
+		},
+	}
+}
+
+func resultsValue(ctx context.Context, results []remote.Result) (types.List, diag.Diagnostics) {
+	models := make([]ResultModel, 0, len(results))
+	for _, r := range results {
+		signal := types.StringNull()
+		if r.ExitSignal != "" {
+			signal = types.StringValue(r.ExitSignal)
+		}
+		models = append(models, ResultModel{
+			Command:    types.StringValue(r.Command),
+			Stdout:     types.StringValue(r.Stdout),
+			Stderr:     types.StringValue(r.Stderr),
+			ExitCode:   types.Int64Value(int64(r.ExitCode)),
+			ExitSignal: signal,
+			StartTime:  types.StringValue(r.Start.UTC().Format(time.RFC3339Nano)),
+			Duration:   types.StringValue(r.Duration.String()),
+		})
+	}
+	return types.ListValueFrom(ctx, types.ObjectType{AttrTypes: resultAttrTypes}, models)
+}
+
+// lastStdout returns the stdout of the last command, which is stored in
+// result.
+func lastStdout(results []remote.Result) types.String {
+	if len(results) == 0 {
+		return types.StringValue("")
+	}
+	return types.StringValue(results[len(results)-1].Stdout)
+}
+
+// outputTail returns the last lines of a command's output for diagnostics.
+func outputTail(output string) string {
+	const maxLines, maxBytes = 20, 2048
+
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return "(empty)"
+	}
+	truncated := false
+	if lines := strings.Split(output, "\n"); len(lines) > maxLines {
+		output = strings.Join(lines[len(lines)-maxLines:], "\n")
+		truncated = true
+	}
+	if len(output) > maxBytes {
+		output = output[len(output)-maxBytes:]
+		truncated = true
+	}
+	if truncated {
+		return "...\n" + output
+	}
+	return output
+}
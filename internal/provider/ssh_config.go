@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	osuser "os/user"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+)
+
+// defaultKnownHostsFile is the known_hosts file ssh reads by default. It
+// verifies ProxyJump hops for which neither the OpenSSH client config nor the
+// provider name a host key source.
+const defaultKnownHostsFile = "~/.ssh/known_hosts"
+
+// applySSHConfig resolves the host through the OpenSSH client config when
+// enabled, filling in settings the provider configuration leaves unset.
+func applySSHConfig(config SshProviderModel, ssh *remote.Config) error {
+	file := "~/.ssh/config"
+	explicit := !config.SSHConfigFile.IsNull()
+	if explicit {
+		file = config.SSHConfigFile.ValueString()
+	} else if !config.UseSSHConfig.ValueBool() {
+		return nil
+	}
+
+	localUser := ""
+	if u, err := osuser.Current(); err == nil {
+		localUser = u.Username
+	}
+
+	resolved, err := remote.ResolveSSHConfig(file, ssh.Host, localUser)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resolved.Apply(ssh)
+
+	if len(ssh.JumpHosts) > 0 {
+		return nil
+	}
+
+	// Hops named by ProxyJump are resolved through the same config and
+	// authenticate with the provider credentials.
+	for _, hop := range remote.ParseProxyJump(resolved.ProxyJump) {
+		hopResolved, err := remote.ResolveSSHConfig(file, hop.Host, localUser)
+		if err != nil {
+			return err
+		}
+		hopResolved.Apply(hop)
+		if hop.HostKeys.IsEmpty() {
+			hop.HostKeys = ssh.HostKeys
+		}
+		if hop.HostKeys.IsEmpty() {
+			if _, err := os.Stat(remote.ExpandPath(defaultKnownHostsFile)); err == nil {
+				hop.HostKeys.KnownHostsFiles = []string{defaultKnownHostsFile}
+			}
+		}
+		if hop.User == "" {
+			hop.User = ssh.User
+		}
+		if hop.Timeout == 0 {
+			hop.Timeout = ssh.Timeout
+		}
+		// Passwords and keyboard-interactive answers are withheld from a
+		// hop whose host key cannot be verified. Public key authentication
+		// discloses nothing to the hop, so keys are still offered.
+		if !hop.HostKeys.IsEmpty() {
+			hop.Password = ssh.Password
+			hop.KeyboardInteractive = ssh.KeyboardInteractive
+		}
+		// Copied, since the certificate signer is appended to both later.
+		hop.Signers = append(hop.Signers[:0:0], ssh.Signers...)
+		hop.Algorithms = ssh.Algorithms
+		hop.PrivateKey = ssh.PrivateKey
+		hop.Passphrase = ssh.Passphrase
+		hop.Agent = ssh.Agent
+		hop.AgentSocket = ssh.AgentSocket
+		hop.AgentIdentity = ssh.AgentIdentity
+		ssh.JumpHosts = append(ssh.JumpHosts, hop)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestApplySSHConfig(t *testing.T) {
+	const sshConfig = `
+Host web
+  HostName web.internal
+  ProxyJump bastion.example.com
+  ConnectTimeout 5
+`
+
+	tests := []struct {
+		name           string
+		hostKeys       remote.HostKeyConfig
+		timeout        time.Duration
+		homeKnownHosts bool
+		want           func(target, hop *remote.Config) bool
+	}{
+		{
+			name:     "hop inherits the provider host keys",
+			hostKeys: remote.HostKeyConfig{Fingerprints: []string{testFingerprint}},
+			want: func(target, hop *remote.Config) bool {
+				return len(hop.HostKeys.Fingerprints) == 1 && hop.Password == "secret" && hop.User == "deploy"
+			},
+		},
+		{
+			name:           "hop falls back to the default known_hosts",
+			homeKnownHosts: true,
+			want: func(target, hop *remote.Config) bool {
+				return len(hop.HostKeys.KnownHostsFiles) == 1 && hop.HostKeys.KnownHostsFiles[0] == defaultKnownHostsFile && hop.Password == "secret"
+			},
+		},
+		{
+			name: "unverified hop gets no password",
+			want: func(target, hop *remote.Config) bool {
+				return hop.HostKeys.IsEmpty() && hop.Password == "" && hop.KeyboardInteractive == nil && hop.PrivateKey == target.PrivateKey
+			},
+		},
+		{
+			name: "timeout from the config",
+			want: func(target, hop *remote.Config) bool {
+				return target.Timeout == 5*time.Second && hop.Timeout == 5*time.Second
+			},
+		},
+		{
+			name:    "configured timeout wins",
+			timeout: 45 * time.Second,
+			want: func(target, hop *remote.Config) bool {
+				return target.Timeout == 45*time.Second && hop.Timeout == 45*time.Second
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if tt.homeKnownHosts {
+				if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			file := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(file, []byte(sshConfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			target := &remote.Config{
+				Host:                "web",
+				User:                "deploy",
+				Password:            "secret",
+				PrivateKey:          testPrivateKey(t),
+				KeyboardInteractive: []remote.PromptResponse{{Pattern: regexp.MustCompile("Verification code"), Answer: "123456"}},
+				HostKeys:            tt.hostKeys,
+				Timeout:             tt.timeout,
+			}
+			if err := applySSHConfig(SshProviderModel{SSHConfigFile: types.StringValue(file)}, target); err != nil {
+				t.Fatalf("applySSHConfig() error = %v", err)
+			}
+			if target.Host != "web.internal" || len(target.JumpHosts) != 1 {
+				t.Fatalf("applySSHConfig() = %+v, want web.internal through one jump host", target)
+			}
+			if hop := target.JumpHosts[0]; !tt.want(target, hop) {
+				t.Errorf("applySSHConfig() hop = %+v", hop)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/appkins/terraform-provider-ssh/internal/remote"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &TunnelDataSource{}
+
+func NewTunnelDataSource() datasource.DataSource {
+	return &TunnelDataSource{}
+}
+
+// tunnels holds the tunnels opened by ssh_tunnel data sources. They stay open
+// for the lifetime of the provider process, and reading the same tunnel again
+// returns the open one.
+var tunnels = struct {
+	sync.Mutex
+	open map[string]*remote.Tunnel
+}{open: map[string]*remote.Tunnel{}}
+
+// CloseTunnels closes the tunnels opened by ssh_tunnel data sources. It is
+// called once the provider server stops.
+func CloseTunnels() error {
+	tunnels.Lock()
+	defer tunnels.Unlock()
+
+	var errs []error
+	for key, t := range tunnels.open {
+		errs = append(errs, t.Close())
+		delete(tunnels.open, key)
+	}
+	return errors.Join(errs...)
+}
+
+// TunnelDataSource defines the data source implementation.
+type TunnelDataSource struct {
+	provider *providerData
+}
+
+// TunnelDataSourceModel describes the data source data model.
+type TunnelDataSourceModel struct {
+	ConnectionProfile types.String `tfsdk:"connection_profile"`
+	RemoteHost        types.String `tfsdk:"remote_host"`
+	RemotePort        types.Int64  `tfsdk:"remote_port"`
+	LocalAddress      types.String `tfsdk:"local_address"`
+	LocalHost         types.String `tfsdk:"local_host"`
+	LocalPort         types.Int64  `tfsdk:"local_port"`
+}
+
+func (d *TunnelDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_tunnel"
+}
+
+func (d *TunnelDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Local port forwarded to `remote_host:remote_port` through the SSH host, the same way as `ssh -L`, so that other providers can reach services only reachable from behind it. " +
+			"The tunnel stays open until the provider process exits. Terraform reads data sources during plan, so make the read happen during apply, e.g. with `depends_on`, when providers use the tunnel while applying.",
+
+		Attributes: map[string]schema.Attribute{
+			"connection_profile": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `connection` profile to tunnel through. Defaults to the provider `host`.",
+				Optional:            true,
+			},
+			"remote_host": schema.StringAttribute{
+				MarkdownDescription: "Host connected to from the SSH host, e.g. `localhost` or a private database address.",
+				Required:            true,
+			},
+			"remote_port": schema.Int64Attribute{
+				MarkdownDescription: "Port connected to on `remote_host`.",
+				Required:            true,
+			},
+			"local_address": schema.StringAttribute{
+				MarkdownDescription: "Local address the tunnel listens on. Defaults to `127.0.0.1` with a free port.",
+				Optional:            true,
+				Computed:            true,
+			},
+			"local_host": schema.StringAttribute{
+				MarkdownDescription: "Host part of `local_address`.",
+				Computed:            true,
+			},
+			"local_port": schema.Int64Attribute{
+				MarkdownDescription: "Port part of `local_address`.",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *TunnelDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	if data, ok := req.ProviderData.(*providerData); !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+	} else {
+		d.provider = data
+	}
+}
+
+func (d *TunnelDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data TunnelDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, diags := d.provider.connection(ctx, data.ConnectionProfile.ValueString(), nil)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	remoteAddress := net.JoinHostPort(data.RemoteHost.ValueString(), strconv.FormatInt(data.RemotePort.ValueInt64(), 10))
+	tunnel, err := openTunnel(ctx, client, data.LocalAddress.ValueString(), remoteAddress)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "open tunnel", err)
+		return
+	}
+
+	local := tunnel.LocalAddress().(*net.TCPAddr)
+	data.LocalAddress = types.StringValue(local.String())
+	data.LocalHost = types.StringValue(local.IP.String())
+	data.LocalPort = types.Int64Value(int64(local.Port))
+
+	tflog.Info(ctx, "Opened tunnel", map[string]interface{}{"local_address": local.String(), "remote_address": remoteAddress})
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// openTunnel returns the tunnel already open in this process for the same
+// host and addresses, or opens a new one.
+func openTunnel(ctx context.Context, client *remote.Provisioner, localAddress, remoteAddress string) (*remote.Tunnel, error) {
+	ssh, err := client.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s@%s/%s/%s", ssh.User, ssh.Address(), localAddress, remoteAddress)
+
+	tunnels.Lock()
+	defer tunnels.Unlock()
+	if t, ok := tunnels.open[key]; ok {
+		return t, nil
+	}
+	t, err := client.Tunnel(localAddress, remoteAddress, ctx)
+	if err != nil {
+		return nil, err
+	}
+	tunnels.open[key] = t
+	return t, nil
+}
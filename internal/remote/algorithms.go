@@ -0,0 +1,194 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Algorithms restricts the algorithms offered during key exchange, in order
+// of preference. Empty lists keep the defaults of golang.org/x/crypto/ssh.
+type Algorithms struct {
+	Ciphers           []string
+	KeyExchanges      []string
+	MACs              []string
+	HostKeyAlgorithms []string
+}
+
+// AlgorithmPresets are named algorithm sets for common compliance needs.
+var AlgorithmPresets = map[string]Algorithms{
+	// fips only offers algorithms approved in FIPS 140-2.
+	"fips": {
+		Ciphers: []string{
+			"aes256-gcm@openssh.com", "aes128-gcm@openssh.com",
+			"aes256-ctr", "aes192-ctr", "aes128-ctr",
+		},
+		KeyExchanges: []string{
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256",
+		},
+		MACs: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com", "hmac-sha2-256",
+		},
+		HostKeyAlgorithms: []string{
+			ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+			ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+			ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+		},
+	},
+	// modern matches the defaults of current OpenSSH releases.
+	"modern": {
+		Ciphers: []string{
+			"chacha20-poly1305@openssh.com",
+			"aes256-gcm@openssh.com", "aes128-gcm@openssh.com",
+			"aes256-ctr", "aes192-ctr", "aes128-ctr",
+		},
+		KeyExchanges: []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256",
+		},
+		MACs: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		},
+		HostKeyAlgorithms: []string{
+			ssh.CertAlgoED25519v01,
+			ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+			ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+			ssh.KeyAlgoED25519,
+			ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+		},
+	},
+	// legacy additionally offers the CBC ciphers, SHA-1 key exchanges and
+	// ssh-rsa/ssh-dss host keys still found on old appliances.
+	"legacy": {
+		Ciphers: []string{
+			"aes128-ctr", "aes192-ctr", "aes256-ctr",
+			"aes128-gcm@openssh.com", "aes256-gcm@openssh.com",
+			"chacha20-poly1305@openssh.com",
+			"aes128-cbc", "3des-cbc",
+			"arcfour256", "arcfour128", "arcfour",
+		},
+		KeyExchanges: []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1",
+			"diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1",
+			"diffie-hellman-group1-sha1",
+		},
+		MACs: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+			"hmac-sha2-256", "hmac-sha1", "hmac-sha1-96",
+		},
+		HostKeyAlgorithms: []string{
+			ssh.CertAlgoED25519v01,
+			ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+			ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01,
+			ssh.KeyAlgoED25519,
+			ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+		},
+	},
+}
+
+// supportedAlgorithms lists the algorithms implemented by
+// golang.org/x/crypto/ssh, which are the legacy preset.
+var supportedAlgorithms = AlgorithmPresets["legacy"]
+
+// Preset returns the named preset overridden by the lists set on a.
+func (a Algorithms) Preset(name string) (Algorithms, error) {
+	preset, ok := AlgorithmPresets[name]
+	if !ok {
+		return a, fmt.Errorf("unknown algorithm preset %q, expected one of %s", name, strings.Join(presetNames(), ", "))
+	}
+	if len(a.Ciphers) > 0 {
+		preset.Ciphers = a.Ciphers
+	}
+	if len(a.KeyExchanges) > 0 {
+		preset.KeyExchanges = a.KeyExchanges
+	}
+	if len(a.MACs) > 0 {
+		preset.MACs = a.MACs
+	}
+	if len(a.HostKeyAlgorithms) > 0 {
+		preset.HostKeyAlgorithms = a.HostKeyAlgorithms
+	}
+	return preset, nil
+}
+
+// Validate reports algorithms that are not supported by the client.
+func (a Algorithms) Validate() error {
+	var errs []error
+	check := func(kind string, names, supported []string) {
+		for _, name := range names {
+			if !contains(supported, name) {
+				errs = append(errs, fmt.Errorf("unsupported %s %q, expected one of %s", kind, name, strings.Join(supported, ", ")))
+			}
+		}
+	}
+	check("cipher", a.Ciphers, supportedAlgorithms.Ciphers)
+	check("key exchange", a.KeyExchanges, supportedAlgorithms.KeyExchanges)
+	check("MAC", a.MACs, supportedAlgorithms.MACs)
+	check("host key algorithm", a.HostKeyAlgorithms, supportedAlgorithms.HostKeyAlgorithms)
+	return errors.Join(errs...)
+}
+
+func (a Algorithms) apply(config *ssh.ClientConfig) {
+	config.Ciphers = a.Ciphers
+	config.KeyExchanges = a.KeyExchanges
+	config.MACs = a.MACs
+	config.HostKeyAlgorithms = a.HostKeyAlgorithms
+}
+
+// AlgorithmError is returned when the client and server have no algorithm
+// in common for one step of the key exchange.
+type AlgorithmError struct {
+	Kind          string
+	ClientOffered []string
+	ServerOffered []string
+}
+
+func (e *AlgorithmError) Error() string {
+	return fmt.Sprintf("no common %s algorithm with the server; client offered: %s; server offered: %s",
+		e.Kind, strings.Join(e.ClientOffered, ", "), strings.Join(e.ServerOffered, ", "))
+}
+
+var algorithmErrorPattern = regexp.MustCompile(`no common algorithm for (.+); client offered: \[(.*)\], server offered: \[(.*)\]`)
+
+// algorithmError turns the negotiation failure reported by the handshake,
+// which is only available as a string, into an AlgorithmError.
+func algorithmError(err error) error {
+	m := algorithmErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	return &AlgorithmError{
+		Kind:          m[1],
+		ClientOffered: strings.Fields(m[2]),
+		ServerOffered: strings.Fields(m[3]),
+	}
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(AlgorithmPresets))
+	for name := range AlgorithmPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAlgorithmsPreset(t *testing.T) {
+	tests := []struct {
+		name    string
+		preset  string
+		algs    Algorithms
+		ciphers []string
+		wantErr bool
+	}{
+		{
+			name:    "preset",
+			preset:  "fips",
+			ciphers: AlgorithmPresets["fips"].Ciphers,
+		},
+		{
+			name:    "override",
+			preset:  "modern",
+			algs:    Algorithms{Ciphers: []string{"aes256-ctr"}},
+			ciphers: []string{"aes256-ctr"},
+		},
+		{
+			name:    "unknown",
+			preset:  "paranoid",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.algs.Preset(tt.preset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Preset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Ciphers, tt.ciphers) {
+				t.Errorf("Ciphers = %v, want %v", got.Ciphers, tt.ciphers)
+			}
+			if len(got.MACs) == 0 || len(got.KeyExchanges) == 0 || len(got.HostKeyAlgorithms) == 0 {
+				t.Errorf("preset lists not filled in: %+v", got)
+			}
+		})
+	}
+}
+
+func TestAlgorithmsValidate(t *testing.T) {
+	for name, preset := range AlgorithmPresets {
+		if err := preset.Validate(); err != nil {
+			t.Errorf("preset %s: %v", name, err)
+		}
+	}
+
+	if err := (Algorithms{MACs: []string{"hmac-md5"}}).Validate(); err == nil {
+		t.Error("expected an error for an unsupported MAC")
+	}
+}
+
+func TestAlgorithmNegotiationError(t *testing.T) {
+	server := newTestServer(t)
+	server.mu.Lock()
+	server.server.Ciphers = []string{"aes128-ctr"}
+	server.mu.Unlock()
+
+	config := server.config("alice")
+	config.Algorithms = Algorithms{Ciphers: []string{"aes256-gcm@openssh.com"}}
+
+	_, err := config.Connect(context.Background())
+
+	var algErr *AlgorithmError
+	if !errors.As(err, &algErr) {
+		t.Fatalf("expected AlgorithmError, got %v", err)
+	}
+	if !reflect.DeepEqual(algErr.ServerOffered, []string{"aes128-ctr"}) {
+		t.Errorf("ServerOffered = %v", algErr.ServerOffered)
+	}
+}
@@ -0,0 +1,224 @@
+package remote
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// authMethods returns the configured authentication methods. Keys are
+// offered in order of precedence: in-memory signers, private key, identity
+// files, then agent keys; keyboard-interactive and password authentication
+// follow. Servers requiring several methods are handled through partial
+// success. The returned closer, when not nil, releases the agent connection
+// once the handshake has completed.
+func (c *Config) authMethods() ([]ssh.AuthMethod, io.Closer, error) {
+	var closer io.Closer
+	var cert *ssh.Certificate
+
+	if c.Certificate != "" {
+		var err error
+		if cert, err = ParseCertificate([]byte(c.Certificate)); err != nil {
+			return nil, nil, err
+		}
+		if err := CheckCertificate(cert, c.User, time.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	signers := append([]ssh.Signer{}, c.Signers...)
+
+	if c.PrivateKey != "" {
+		signer, err := ParsePrivateKey([]byte(c.PrivateKey), c.Passphrase)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse private key: %w", err)
+		}
+		signers = append(signers, signer)
+	}
+
+	if len(c.IdentityFiles) > 0 {
+		files, err := identityFileSigners(c.IdentityFiles, c.Passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		signers = append(signers, files...)
+	}
+
+	signers, certMatched, err := certSigners(signers, cert)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cert != nil && !certMatched && !c.Agent {
+		return nil, nil, &CertificateError{KeyID: cert.KeyId, Reason: "it does not certify the configured private key"}
+	}
+
+	// The client tries each method name once, so all keys are offered
+	// through a single publickey method.
+	var agentKeys func() ([]ssh.Signer, error)
+	if c.Agent {
+		conn, err := c.dialAgent()
+		if err != nil {
+			return nil, nil, err
+		}
+		closer = conn
+		agentKeys = agentSigners(agent.NewClient(conn), c.AgentIdentity, cert)
+	}
+
+	auths := []ssh.AuthMethod{}
+
+	if len(signers) > 0 || agentKeys != nil {
+		auths = append(auths, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if agentKeys == nil {
+				return signers, nil
+			}
+			keys, err := agentKeys()
+			if err != nil && len(signers) == 0 {
+				return nil, err
+			}
+			return append(append([]ssh.Signer{}, signers...), keys...), nil
+		}))
+	}
+
+	if len(c.KeyboardInteractive) > 0 {
+		auths = append(auths, ssh.KeyboardInteractive(keyboardInteractive(c.KeyboardInteractive)))
+	}
+
+	if c.Password != "" {
+		auths = append(auths, ssh.Password(c.Password))
+	}
+
+	return auths, closer, nil
+}
+
+// identityFileSigners loads the identity files that exist. Encrypted files
+// are skipped when no passphrase is configured.
+func identityFileSigners(files []string, passphrase string) ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	for _, f := range files {
+		key, err := os.ReadFile(ExpandPath(f))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ParsePrivateKey(key, passphrase)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key %s: %w", f, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// ParsePrivateKey parses a PEM encoded private key, decrypting it with the
+// passphrase when it is encrypted. Encrypted OpenSSH, legacy PEM and PKCS#8
+// keys are supported.
+func ParsePrivateKey(key []byte, passphrase string) (ssh.Signer, error) {
+	raw, err := parseRawPrivateKey(key, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(raw)
+}
+
+// parseRawPrivateKey is ParsePrivateKey returning the crypto key, as needed
+// to load the key into an agent keyring.
+func parseRawPrivateKey(key []byte, passphrase string) (interface{}, error) {
+	if block, _ := pem.Decode(key); block != nil && block.Type == "ENCRYPTED PRIVATE KEY" {
+		if passphrase == "" {
+			return nil, &ssh.PassphraseMissingError{}
+		}
+		return pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+	}
+
+	raw, err := ssh.ParseRawPrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && passphrase != "" {
+		return ssh.ParseRawPrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
+	return raw, err
+}
+
+func (c *Config) dialAgent() (net.Conn, error) {
+	socket := c.AgentSocket
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return nil, fmt.Errorf("ssh agent requested but no agent socket is configured and SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", ExpandPath(socket))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to ssh agent at %s: %w", socket, err)
+	}
+	return conn, nil
+}
+
+// agentSigners returns the agent keys to offer. When identity is set only
+// keys whose comment, SHA256 fingerprint or public key matches it are used.
+// The key certified by cert, if any, is offered with the certificate.
+func agentSigners(client agent.Agent, identity string, cert *ssh.Certificate) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		signers, err := client.Signers()
+		if err != nil {
+			return nil, err
+		}
+		if identity == "" {
+			signers, _, err = certSigners(signers, cert)
+			return signers, err
+		}
+
+		keys, err := client.List()
+		if err != nil {
+			return nil, err
+		}
+
+		allowed := make(map[string]bool)
+		for _, key := range keys {
+			if matchesIdentity(key, identity) {
+				allowed[string(key.Marshal())] = true
+			}
+		}
+
+		filtered := make([]ssh.Signer, 0, len(allowed))
+		for _, signer := range signers {
+			if allowed[string(signer.PublicKey().Marshal())] {
+				filtered = append(filtered, signer)
+			}
+		}
+		if len(filtered) == 0 {
+			return nil, fmt.Errorf("no ssh agent key matches identity %q", identity)
+		}
+		filtered, _, err = certSigners(filtered, cert)
+		return filtered, err
+	}
+}
+
+func matchesIdentity(key *agent.Key, identity string) bool {
+	identity = strings.TrimSpace(identity)
+	if key.Comment == identity {
+		return true
+	}
+	if ssh.FingerprintSHA256(key) == normalizeFingerprint(identity) {
+		return true
+	}
+	if pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(identity)); err == nil {
+		return string(pub.Marshal()) == string(key.Marshal())
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAgentSigners(t *testing.T) {
+	keyring := agent.NewKeyring()
+	fingerprints := map[string]string{}
+	for _, comment := range []string{"laptop", "yubikey"} {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: priv, Comment: comment}); err != nil {
+			t.Fatal(err)
+		}
+		signer, _ := ssh.NewSignerFromKey(priv)
+		fingerprints[comment] = ssh.FingerprintSHA256(signer.PublicKey())
+	}
+
+	tests := []struct {
+		name     string
+		identity string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "all keys", identity: "", want: []string{fingerprints["laptop"], fingerprints["yubikey"]}},
+		{name: "by comment", identity: "yubikey", want: []string{fingerprints["yubikey"]}},
+		{name: "by fingerprint", identity: fingerprints["laptop"], want: []string{fingerprints["laptop"]}},
+		{name: "no match", identity: "desktop", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signers, err := agentSigners(keyring, tt.identity, nil)()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("agentSigners() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := map[string]bool{}
+			for _, s := range signers {
+				got[ssh.FingerprintSHA256(s.PublicKey())] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("agentSigners() returned %d keys, want %d", len(got), len(tt.want))
+			}
+			for _, w := range tt.want {
+				if !got[w] {
+					t.Errorf("agentSigners() missing key %s", w)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainDER, err := pkcs8.MarshalPrivateKey(priv, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedDER, err := pkcs8.MarshalPrivateKey(priv, []byte("hunter2"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: plainDER})
+	encrypted := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER})
+
+	tests := []struct {
+		name       string
+		key        []byte
+		passphrase string
+		wantErr    bool
+	}{
+		{name: "plain", key: plain},
+		{name: "plain with passphrase", key: plain, passphrase: "hunter2"},
+		{name: "pkcs8 encrypted", key: encrypted, passphrase: "hunter2"},
+		{name: "pkcs8 wrong passphrase", key: encrypted, passphrase: "wrong", wantErr: true},
+		{name: "pkcs8 missing passphrase", key: encrypted, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePrivateKey(tt.key, tt.passphrase)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunCredentialCommand(t *testing.T) {
+	creds, err := RunCredentialCommand(context.Background(), `echo '{"password": "s3cret"}'`)
+	if err != nil {
+		t.Fatalf("RunCredentialCommand() error = %v", err)
+	}
+	if creds.Password != "s3cret" {
+		t.Errorf("RunCredentialCommand() password = %q, want %q", creds.Password, "s3cret")
+	}
+
+	if _, err := RunCredentialCommand(context.Background(), `echo '{}'`); err == nil {
+		t.Error("RunCredentialCommand() with empty document error = nil, want error")
+	}
+}
@@ -0,0 +1,197 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CertificateError reports a user certificate that cannot be used.
+type CertificateError struct {
+	KeyID  string
+	Reason string
+}
+
+func (e *CertificateError) Error() string {
+	return fmt.Sprintf("certificate %q rejected: %s", e.KeyID, e.Reason)
+}
+
+// ParseCertificate parses an OpenSSH user certificate in authorized_keys format.
+func ParseCertificate(content []byte) (*ssh.Certificate, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %w", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse certificate: %s is a public key, not a certificate", key.Type())
+	}
+	return cert, nil
+}
+
+// CheckCertificate verifies that cert is a user certificate that is valid at
+// now and lists user among its principals.
+func CheckCertificate(cert *ssh.Certificate, user string, now time.Time) error {
+	if cert.CertType != ssh.UserCert {
+		return &CertificateError{KeyID: cert.KeyId, Reason: "it is a host certificate, not a user certificate"}
+	}
+
+	unix := uint64(now.Unix())
+	if unix < cert.ValidAfter {
+		return &CertificateError{KeyID: cert.KeyId, Reason: fmt.Sprintf("it is not valid before %s", certTime(cert.ValidAfter))}
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && unix >= cert.ValidBefore {
+		return &CertificateError{KeyID: cert.KeyId, Reason: fmt.Sprintf("it expired at %s", certTime(cert.ValidBefore))}
+	}
+
+	if len(cert.ValidPrincipals) == 0 {
+		return nil
+	}
+	for _, principal := range cert.ValidPrincipals {
+		if principal == user {
+			return nil
+		}
+	}
+	return &CertificateError{KeyID: cert.KeyId, Reason: fmt.Sprintf("user %q is not among its principals %q", user, cert.ValidPrincipals)}
+}
+
+func certTime(t uint64) string {
+	return time.Unix(int64(t), 0).UTC().Format(time.RFC3339)
+}
+
+// certSigners replaces the signer matching the certificate key with a
+// certificate signer and moves it to the front. It reports whether any
+// signer matched.
+func certSigners(signers []ssh.Signer, cert *ssh.Certificate) ([]ssh.Signer, bool, error) {
+	if cert == nil {
+		return signers, false, nil
+	}
+
+	matched := false
+	result := make([]ssh.Signer, 0, len(signers)+1)
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), cert.Key.Marshal()) {
+			certSigner, err := ssh.NewCertSigner(cert, signer)
+			if err != nil {
+				return nil, false, err
+			}
+			result = append([]ssh.Signer{certSigner}, result...)
+			matched = true
+			continue
+		}
+		result = append(result, signer)
+	}
+	return result, matched, nil
+}
+
+// CertificateRequest describes a short-lived user certificate to sign.
+type CertificateRequest struct {
+	KeyID           string
+	Principals      []string
+	TTL             time.Duration
+	Extensions      map[string]string
+	CriticalOptions map[string]string
+}
+
+// certificateClockSkew backdates certificates so that hosts with a slightly
+// slow clock accept them immediately.
+const certificateClockSkew = time.Minute
+
+// CertificateSigner presents a certificate for an in-memory ed25519 key
+// pair. A new certificate for the same key is signed with the CA once less
+// than half of the lifetime of the current one remains, so that connections
+// opened late in a long run still authenticate.
+type CertificateSigner struct {
+	ca  ssh.Signer
+	req CertificateRequest
+	key ssh.Signer
+	now func() time.Time
+
+	mu   sync.Mutex
+	cert *ssh.Certificate
+}
+
+// NewCertificateSigner generates a key pair and signs its first certificate.
+func NewCertificateSigner(ca ssh.Signer, req CertificateRequest) (*CertificateSigner, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &CertificateSigner{ca: ca, req: req, key: key, now: time.Now}
+	if err := s.renew(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Certificate returns the current certificate, signing a new one when it is
+// close to expiry.
+func (s *CertificateSigner) Certificate() (*ssh.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remaining := time.Unix(int64(s.cert.ValidBefore), 0).Sub(s.now())
+	if remaining < s.req.TTL/2 {
+		if err := s.renew(); err != nil {
+			return nil, err
+		}
+	}
+	return s.cert, nil
+}
+
+// PublicKey returns the current certificate. When it cannot be renewed the
+// expiring one is returned, for the server to reject.
+func (s *CertificateSigner) PublicKey() ssh.PublicKey {
+	cert, err := s.Certificate()
+	if err != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.cert
+	}
+	return cert
+}
+
+// Sign signs data with the key pair, which every certificate certifies.
+func (s *CertificateSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.key.Sign(rand, data)
+}
+
+// renew signs a new certificate. s.mu must be held once s is shared.
+func (s *CertificateSigner) renew() error {
+	serial := make([]byte, 8)
+	if _, err := rand.Read(serial); err != nil {
+		return err
+	}
+
+	now := s.now()
+	cert := &ssh.Certificate{
+		Key:             s.key.PublicKey(),
+		Serial:          binary.BigEndian.Uint64(serial),
+		CertType:        ssh.UserCert,
+		KeyId:           s.req.KeyID,
+		ValidPrincipals: s.req.Principals,
+		ValidAfter:      uint64(now.Add(-certificateClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(s.req.TTL).Unix()),
+		Permissions: ssh.Permissions{
+			CriticalOptions: s.req.CriticalOptions,
+			Extensions:      s.req.Extensions,
+		},
+	}
+	if err := cert.SignCert(rand.Reader, s.ca); err != nil {
+		return fmt.Errorf("unable to sign certificate: %w", err)
+	}
+	s.cert = cert
+	return nil
+}
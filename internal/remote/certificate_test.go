@@ -0,0 +1,148 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCheckCertificate(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	newCert := func(certType uint32, principals []string, after, before time.Time) *ssh.Certificate {
+		cert := &ssh.Certificate{
+			Key:             newTestPublicKey(t),
+			KeyId:           "deploy@ci",
+			CertType:        certType,
+			ValidPrincipals: principals,
+			ValidAfter:      uint64(after.Unix()),
+			ValidBefore:     uint64(before.Unix()),
+		}
+		if err := cert.SignCert(rand.Reader, ca); err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	tests := []struct {
+		name       string
+		cert       *ssh.Certificate
+		wantReason string
+	}{
+		{name: "valid", cert: newCert(ssh.UserCert, []string{"deploy"}, now.Add(-time.Minute), now.Add(time.Hour))},
+		{name: "any principal", cert: newCert(ssh.UserCert, nil, now.Add(-time.Minute), now.Add(time.Hour))},
+		{name: "host certificate", cert: newCert(ssh.HostCert, []string{"deploy"}, now.Add(-time.Minute), now.Add(time.Hour)), wantReason: "not a user certificate"},
+		{name: "not yet valid", cert: newCert(ssh.UserCert, []string{"deploy"}, now.Add(time.Minute), now.Add(time.Hour)), wantReason: "not valid before"},
+		{name: "expired", cert: newCert(ssh.UserCert, []string{"deploy"}, now.Add(-time.Hour), now.Add(-time.Minute)), wantReason: "expired at"},
+		{name: "wrong principal", cert: newCert(ssh.UserCert, []string{"root"}, now.Add(-time.Minute), now.Add(time.Hour)), wantReason: "not among its principals"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCertificate(tt.cert, "deploy", now)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("CheckCertificate() error = %v", err)
+				}
+				return
+			}
+			var certErr *CertificateError
+			if !errors.As(err, &certErr) || !strings.Contains(certErr.Reason, tt.wantReason) {
+				t.Errorf("CheckCertificate() error = %v, want reason %q", err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestCertificateSigner(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewCertificateSigner(ca, CertificateRequest{
+		KeyID:      "ci",
+		Principals: []string{"deploy"},
+		TTL:        5 * time.Minute,
+		Extensions: map[string]string{"permit-pty": ""},
+	})
+	if err != nil {
+		t.Fatalf("NewCertificateSigner() error = %v", err)
+	}
+	cert, err := signer.Certificate()
+	if err != nil {
+		t.Fatalf("Certificate() error = %v", err)
+	}
+	if err := CheckCertificate(cert, "deploy", time.Now()); err != nil {
+		t.Errorf("CheckCertificate() error = %v", err)
+	}
+	if err := CheckCertificate(cert, "deploy", time.Now().Add(6*time.Minute)); err == nil {
+		t.Error("CheckCertificate() after ttl error = nil, want expired")
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+		},
+	}
+	if _, err := checker.Authenticate(testConnMetadata("deploy"), signer.PublicKey()); err != nil {
+		t.Errorf("CertChecker.Authenticate() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		renewed bool
+	}{
+		{name: "fresh", elapsed: time.Minute, renewed: false},
+		{name: "close to expiry", elapsed: 3 * time.Minute, renewed: true},
+		{name: "expired", elapsed: 10 * time.Minute, renewed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Add(tt.elapsed)
+			signer.mu.Lock()
+			signer.cert = cert
+			signer.now = func() time.Time { return now }
+			signer.mu.Unlock()
+
+			got := signer.PublicKey().(*ssh.Certificate)
+			if renewed := got.Serial != cert.Serial; renewed != tt.renewed {
+				t.Fatalf("renewed = %t, want %t", renewed, tt.renewed)
+			}
+			if err := CheckCertificate(got, "deploy", now); tt.renewed && err != nil {
+				t.Errorf("CheckCertificate() of renewed certificate error = %v", err)
+			}
+			if !bytes.Equal(got.Key.Marshal(), cert.Key.Marshal()) {
+				t.Error("renewed certificate certifies a different key")
+			}
+		})
+	}
+}
+
+type testConnMetadata string
+
+func (m testConnMetadata) User() string          { return string(m) }
+func (m testConnMetadata) SessionID() []byte     { return nil }
+func (m testConnMetadata) ClientVersion() []byte { return nil }
+func (m testConnMetadata) ServerVersion() []byte { return nil }
+func (m testConnMetadata) RemoteAddr() net.Addr  { return &net.TCPAddr{} }
+func (m testConnMetadata) LocalAddr() net.Addr   { return &net.TCPAddr{} }
@@ -0,0 +1,377 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func (c *Config) clientConfig() (*ssh.ClientConfig, io.Closer, error) {
+	auths, closer, err := c.authMethods()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostKeyCallback, err := c.HostKeys.Callback()
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         c.Timeout,
+	}
+	c.Algorithms.apply(config)
+	return config, closer, nil
+}
+
+// Connect dials the host, tunnelling through each jump host in order, and
+// completes the SSH handshake. Closing the returned client also closes the
+// connections to the jump hosts.
+func (c *Config) Connect(ctx context.Context) (*ssh.Client, error) {
+	var hops []*ssh.Client
+	closeHops := func() {
+		for i := len(hops) - 1; i >= 0; i-- {
+			closeClient(hops[i])
+		}
+	}
+
+	var via *ssh.Client
+	for _, hop := range c.JumpHosts {
+		client, err := c.connect(ctx, hop, via)
+		if err != nil {
+			closeHops()
+			return nil, fmt.Errorf("unable to connect to jump host %s: %w", hop.Address(), err)
+		}
+		hops = append(hops, client)
+		via = client
+	}
+
+	client, err := c.connect(ctx, c, via)
+	if err != nil {
+		closeHops()
+		return nil, err
+	}
+
+	if len(hops) > 0 {
+		go func() {
+			_ = client.Wait()
+			closeHops()
+		}()
+	}
+	return client, nil
+}
+
+// dial opens the transport to hop. Only the first hop of a chain is dialed
+// from this machine, using the proxy command or proxy configured on c; later
+// hops are reached through via.
+func (c *Config) dial(ctx context.Context, hop *Config, via *ssh.Client) (net.Conn, error) {
+	addr := hop.Address()
+	switch {
+	case via != nil:
+		return via.Dial("tcp", addr)
+	case c.ProxyCommand != "":
+		return dialCommand(ctx, c.ProxyCommand, hop)
+	case c.Proxy != nil && !c.Proxy.bypass(hop.Host):
+		return c.Proxy.dial(ctx, addr, hop.Timeout)
+	default:
+		dialer := net.Dialer{Timeout: hop.Timeout}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+}
+
+// connect opens a single SSH connection to hop.
+func (c *Config) connect(ctx context.Context, hop *Config, via *ssh.Client) (*ssh.Client, error) {
+	config, closer, err := hop.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	// The handshake flattens callback errors into strings, so keep hold of
+	// the host key error to return it intact.
+	var hostKeyErr error
+	verify := config.HostKeyCallback
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKeyErr = verify(hostname, remote, key)
+		if hostKeyErr == nil && hop == c && c.hostKeyObserver != nil {
+			c.hostKeyObserver(key)
+		}
+		return hostKeyErr
+	}
+
+	conn, err := c.dial(ctx, hop, via)
+	if err != nil {
+		return nil, &Error{Kind: ErrorDial, Err: err}
+	}
+
+	// ClientConfig.Timeout only bounds dialing, so bound the handshake by the
+	// timeout and ctx as well. Connections without deadlines, such as
+	// channels through a jump host, are closed once either expires.
+	handshakeCtx, cancel := ctx, context.CancelFunc(func() {})
+	if hop.Timeout > 0 {
+		handshakeCtx, cancel = context.WithTimeout(ctx, hop.Timeout)
+	}
+	defer cancel()
+	if deadline, ok := handshakeCtx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	handshakeDone := make(chan struct{})
+	expired := make(chan bool, 1)
+	go func() {
+		select {
+		case <-handshakeCtx.Done():
+			conn.Close()
+			expired <- true
+		case <-handshakeDone:
+			expired <- false
+		}
+	}()
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, hop.Address(), config)
+	close(handshakeDone)
+	if <-expired || handshakeCtx.Err() != nil {
+		if err == nil {
+			sshConn.Close()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &Error{Kind: ErrorDial, Err: fmt.Errorf("ssh handshake with %s did not complete within %s", hop.Address(), hop.Timeout)}
+	}
+	if err != nil {
+		conn.Close()
+		if hostKeyErr != nil {
+			return nil, hostKeyErr
+		}
+		if cmd, ok := conn.(*commandConn); ok && cmd.output() != "" {
+			return nil, &Error{Kind: ErrorDial, Err: fmt.Errorf("%w: proxy command: %s", err, cmd.output())}
+		}
+		return nil, handshakeError(err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	if cmd, ok := conn.(*commandConn); ok {
+		cmd.release()
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	if c.KeepaliveInterval > 0 {
+		go keepalive(client, hop.Address(), c.KeepaliveInterval, c.KeepaliveMaxMissed)
+	}
+	return client, nil
+}
+
+// client returns a pooled connection, or a new connection when c has no
+// pool. release must be called once the connection is no longer used.
+func (c *Config) client(ctx context.Context) (client *ssh.Client, release func(), err error) {
+	if c.Pool != nil {
+		return c.Pool.Get(ctx, c)
+	}
+	if client, err = c.Connect(ctx); err != nil {
+		return nil, nil, err
+	}
+	return client, func() { closeClient(client) }, nil
+}
+
+// session opens a session on a pooled connection, or on a new connection
+// when c has no pool. closeSession must be called once the session is done.
+func (c *Config) session(ctx context.Context) (client *ssh.Client, session *ssh.Session, closeSession func(), err error) {
+	if c.Pool == nil {
+		client, err := c.Connect(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		session, err := client.NewSession()
+		if err != nil {
+			err = &Error{Kind: ErrorSession, Err: keepaliveError(client, err)}
+			closeClient(client)
+			return nil, nil, nil, err
+		}
+		return client, session, func() {
+			session.Close()
+			closeClient(client)
+		}, nil
+	}
+
+	// A pooled connection may have been dropped since it was last used,
+	// so replace it once before giving up. A session refused by the host,
+	// e.g. because other operations reached its MaxSessions, leaves the
+	// connection in the pool for them.
+	for attempt := 0; ; attempt++ {
+		client, release, err := c.Pool.Get(ctx, c)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		session, err := client.NewSession()
+		if err != nil {
+			var openErr *ssh.OpenChannelError
+			refused := errors.As(err, &openErr)
+			err = &Error{Kind: ErrorSession, Err: keepaliveError(client, err)}
+			release()
+			if refused {
+				return nil, nil, nil, err
+			}
+			c.Pool.Discard(client)
+			if attempt == 0 {
+				continue
+			}
+			return nil, nil, nil, err
+		}
+		return client, session, func() {
+			session.Close()
+			release()
+		}, nil
+	}
+}
+
+// Run executes a command on the host and returns its stdout and stderr.
+func (c *Config) Run(ctx context.Context, command string, timeout time.Duration) (string, string, error) {
+	result, err := c.RunCommand(ctx, Command{Command: command}, timeout)
+	return result.Stdout, result.Stderr, err
+}
+
+// RunCommand executes a command on the host with its options. The result is
+// returned even when the command fails, with ExitCode -1 when the command
+// did not report an exit status, e.g. because it timed out.
+func (c *Config) RunCommand(ctx context.Context, cmd Command, timeout time.Duration) (*Result, error) {
+	if cmd.Script != "" {
+		return c.runScript(ctx, cmd, timeout)
+	}
+
+	result := &Result{Command: cmd.Command, ExitCode: -1, Start: time.Now()}
+	defer func() { result.Duration = time.Since(result.Start) }()
+
+	client, session, closeSession, err := c.session(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer closeSession()
+
+	if cmd.ForwardAgent || c.ForwardAgent {
+		if err := c.forwardAgent(client); err != nil {
+			return result, err
+		}
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return result, &Error{Kind: ErrorSession, Err: fmt.Errorf("unable to forward agent: %w", err)}
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	exports := setEnvironment(session, c.Environment, c.SensitiveEnvironment, cmd.Environment, cmd.SensitiveEnvironment)
+	sensitive := map[string]bool{}
+	for name := range exports {
+		_, fromConfig := c.SensitiveEnvironment[name]
+		_, fromCommand := cmd.SensitiveEnvironment[name]
+		sensitive[name] = fromConfig || fromCommand
+	}
+	command, stdin := exportEnvironment(exports, sensitive, cmd.Command)
+	if stdin != "" {
+		session.Stdin = strings.NewReader(stdin)
+	}
+	if err := session.Start(command); err != nil {
+		return result, &Error{Kind: ErrorSession, Err: keepaliveError(client, err)}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
+	}
+
+	// Stop the command and wait for its output to be copied, so that what
+	// it printed until now is returned.
+	kill := func() {
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+	}
+
+	select {
+	case err = <-done:
+	case <-timer:
+		kill()
+		err = &Error{Kind: ErrorTimeout, Err: fmt.Errorf("command timed out after %s", timeout)}
+	case <-ctx.Done():
+		kill()
+		err = ctx.Err()
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	var exitErr *ssh.ExitError
+	var exitMissingErr *ssh.ExitMissingError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+		result.ExitSignal = exitErr.Signal()
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitMissingErr), errors.Is(err, io.EOF):
+		// The connection was lost while the command ran.
+		err = &Error{Kind: ErrorDisconnect, Err: err}
+	}
+	return result, keepaliveError(client, err)
+}
+
+// isExitError reports whether err is a command's exit status rather than a
+// failure to run it.
+func isExitError(err error) bool {
+	var exitErr *ssh.ExitError
+	return errors.As(err, &exitErr)
+}
+
+// WriteFile reads size bytes from the reader and writes them to a file on the host.
+func (c *Config) WriteFile(ctx context.Context, reader io.Reader, size int64, target string) error {
+	client, session, closeSession, err := c.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeSession()
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	copyErr := make(chan error, 1)
+	go func() {
+		defer w.Close()
+		if _, err := fmt.Fprintln(w, "C0644", size, filepath.Base(target)); err != nil {
+			copyErr <- err
+			return
+		}
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			copyErr <- err
+			return
+		}
+		_, err := fmt.Fprint(w, "\x00")
+		copyErr <- err
+	}()
+
+	if err := session.Run(fmt.Sprintf("scp -t \"%s\"", target)); err != nil {
+		return keepaliveError(client, err)
+	}
+	return <-copyErr
+}
@@ -0,0 +1,258 @@
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestConfig_RunThroughJumpHosts(t *testing.T) {
+	first := newTestServer(t)
+	second := newTestServer(t)
+	target := newTestServer(t)
+
+	config := target.config("deploy")
+	config.JumpHosts = []*Config{first.config("jump"), second.config("jump")}
+
+	stdout, _, err := config.Run(context.Background(), "uptime", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Config.Run() error = %v", err)
+	}
+	if stdout != "uptime\n" {
+		t.Errorf("Config.Run() stdout = %q, want %q", stdout, "uptime\n")
+	}
+	for name, s := range map[string]*testServer{"first": first, "second": second, "target": target} {
+		if got := s.connections(); got != 1 {
+			t.Errorf("%s server connections = %d, want 1", name, got)
+		}
+	}
+}
+
+func TestConfig_ConnectHostKeyMismatch(t *testing.T) {
+	jump := newTestServer(t)
+	target := newTestServer(t)
+
+	config := target.config("deploy")
+	jumpConfig := jump.config("jump")
+	jumpConfig.HostKeys.Fingerprints = []string{"SHA256:AAAA"}
+	config.JumpHosts = []*Config{jumpConfig}
+
+	_, err := config.Connect(context.Background())
+	var hostKeyErr *HostKeyError
+	if !errors.As(err, &hostKeyErr) {
+		t.Fatalf("Config.Connect() error = %v, want *HostKeyError", err)
+	}
+}
+
+func TestConfig_RunCommandForwardAgent(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv, Comment: "laptop"}); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name    string
+		config  func(c *Config)
+		command Command
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "not requested",
+			config:  func(c *Config) { c.PrivateKey = privateKey },
+			command: Command{Command: "ssh-add -l"},
+			want:    "ssh-add -l\n",
+		},
+		{
+			name:    "private key",
+			config:  func(c *Config) { c.PrivateKey = privateKey },
+			command: Command{Command: "ssh-add -l", ForwardAgent: true},
+			want:    "ssh-add -l\nagent: terraform-provider-ssh\n",
+		},
+		{
+			name: "local agent",
+			config: func(c *Config) {
+				c.Agent = true
+				c.AgentSocket = socket
+			},
+			command: Command{Command: "ssh-add -l", ForwardAgent: true},
+			want:    "ssh-add -l\nagent: laptop\n",
+		},
+		{
+			name: "config default",
+			config: func(c *Config) {
+				c.PrivateKey = privateKey
+				c.ForwardAgent = true
+			},
+			command: Command{Command: "ssh-add -l"},
+			want:    "ssh-add -l\nagent: terraform-provider-ssh\n",
+		},
+		{
+			name:    "nothing to forward",
+			config:  func(c *Config) {},
+			command: Command{Command: "ssh-add -l", ForwardAgent: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestServer(t).config("deploy")
+			tt.config(config)
+
+			result, err := config.RunCommand(context.Background(), tt.command, 10*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config.RunCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.Stdout != tt.want {
+				t.Errorf("Config.RunCommand() stdout = %q, want %q", result.Stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_RunCommandResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		wantStderr string
+		wantCode   int
+		wantSignal string
+		wantErr    bool
+	}{
+		{name: "success", command: "true", wantCode: 0},
+		{name: "exit status", command: "exit 3", wantStderr: "exiting with 3\n", wantCode: 3, wantErr: true},
+		{name: "signal", command: "kill TERM", wantCode: 143, wantSignal: "TERM", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestServer(t).config("deploy")
+
+			result, err := config.RunCommand(context.Background(), Command{Command: tt.command}, 10*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config.RunCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.Command != tt.command || result.Stdout != tt.command+"\n" || result.Stderr != tt.wantStderr {
+				t.Errorf("Config.RunCommand() = %+v", result)
+			}
+			if result.ExitCode != tt.wantCode || result.ExitSignal != tt.wantSignal {
+				t.Errorf("Config.RunCommand() exit = %d %q, want %d %q", result.ExitCode, result.ExitSignal, tt.wantCode, tt.wantSignal)
+			}
+			if result.Start.IsZero() || result.Duration <= 0 {
+				t.Errorf("Config.RunCommand() timing = %s %s", result.Start, result.Duration)
+			}
+		})
+	}
+}
+
+func TestConfig_ConnectSilentHost(t *testing.T) {
+	// A host that accepts connections and never speaks.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		proxyCommand string
+		cancelAfter  time.Duration
+		want         func(err error) bool
+	}{
+		{name: "timeout", timeout: 100 * time.Millisecond, want: isDialError},
+		{name: "context deadline", cancelAfter: 100 * time.Millisecond, want: isDeadlineError},
+		{name: "proxy command timeout", timeout: 100 * time.Millisecond, proxyCommand: "sleep 10", want: isDialError},
+		{name: "proxy command context deadline", cancelAfter: 100 * time.Millisecond, proxyCommand: "sleep 10", want: isDeadlineError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Host:         host,
+				Port:         port,
+				User:         "deploy",
+				Password:     "secret",
+				Timeout:      tt.timeout,
+				ProxyCommand: tt.proxyCommand,
+				HostKeys:     HostKeyConfig{Fingerprints: []string{"SHA256:AAAA"}},
+			}
+			ctx := context.Background()
+			if tt.cancelAfter > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.cancelAfter)
+				defer cancel()
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := config.Connect(ctx)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Fatal("Config.Connect() succeeded against a silent host")
+				}
+				if !tt.want(err) {
+					t.Errorf("Config.Connect() error = %v of kind %q", err, Classify(err))
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Config.Connect() hung on a silent host")
+			}
+		})
+	}
+}
+
+func isDialError(err error) bool { return Classify(err) == ErrorDial }
+
+func isDeadlineError(err error) bool { return errors.Is(err, context.DeadlineExceeded) }
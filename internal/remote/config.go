@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Config holds the settings needed to open an SSH connection to a host.
+type Config struct {
+	Host       string
+	Port       string
+	User       string
+	Password   string
+	PrivateKey string
+	// Passphrase decrypts PrivateKey and IdentityFiles when they are encrypted.
+	Passphrase string
+	Timeout    time.Duration
+	HostKeys   HostKeyConfig
+
+	// Algorithms restricts the ciphers, key exchanges, MACs and host key
+	// algorithms offered to the server.
+	Algorithms Algorithms
+
+	// Signers are offered before any other key, e.g. an ephemeral
+	// certificate signed by the provider.
+	Signers []ssh.Signer
+
+	// Certificate is an OpenSSH user certificate, in authorized_keys format,
+	// offered with the key it certifies from PrivateKey, IdentityFiles or
+	// the agent.
+	Certificate string
+
+	// IdentityFiles lists private key files to offer after PrivateKey.
+	// Files that do not exist are skipped.
+	IdentityFiles []string
+
+	// KeyboardInteractive answers keyboard-interactive prompts, e.g. a
+	// one-time password requested after publickey partial success.
+	KeyboardInteractive []PromptResponse
+
+	// Agent enables authentication with keys held by an SSH agent.
+	Agent bool
+	// AgentSocket overrides the agent socket path, which defaults to SSH_AUTH_SOCK.
+	AgentSocket string
+	// AgentIdentity limits the agent keys offered to those matching a
+	// comment, SHA256 fingerprint or public key.
+	AgentIdentity string
+
+	// ForwardAgent forwards an agent to every command, see Command.ForwardAgent.
+	ForwardAgent bool
+
+	// Environment and SensitiveEnvironment are set for every command, below
+	// the environment of the command itself. Sensitive values are masked in
+	// logs.
+	Environment          map[string]string
+	SensitiveEnvironment map[string]string
+
+	// JumpHosts are tunnelled through in order before reaching the host,
+	// the same way as ssh -J. Jump hosts and proxies set on a jump host are
+	// ignored.
+	JumpHosts []*Config
+
+	// Proxy is used to reach the first jump host, or the host itself when
+	// there are no jump hosts.
+	Proxy *ProxyConfig
+
+	// KeepaliveInterval is how often keepalive@openssh.com requests are
+	// sent on every connection of the chain. Zero disables keepalives.
+	KeepaliveInterval time.Duration
+	// KeepaliveMaxMissed is the number of unanswered keepalives after which
+	// a connection is declared dead. Defaults to 3.
+	KeepaliveMaxMissed int
+
+	// Pool shares connections between operations. When nil, each
+	// operation opens its own connection.
+	Pool *Pool
+
+	// hostKeyObserver is called with the host key accepted for the host,
+	// but not for jump hosts.
+	hostKeyObserver func(ssh.PublicKey)
+
+	// ProxyCommand is run locally to reach the first jump host, or the
+	// host itself, and the SSH protocol is spoken over its stdin and
+	// stdout. %h, %p and %r expand to the host, port and user. It takes
+	// precedence over Proxy.
+	ProxyCommand string
+}
+
+// Address returns the host:port pair used to dial the host.
+func (c *Config) Address() string {
+	port := c.Port
+	if port == "" {
+		port = "22"
+	}
+	return net.JoinHostPort(c.Host, port)
+}
+
+// Clone returns a copy of c whose lists can be changed without affecting c.
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Signers = append([]ssh.Signer(nil), c.Signers...)
+	clone.IdentityFiles = append([]string(nil), c.IdentityFiles...)
+	clone.JumpHosts = append([]*Config(nil), c.JumpHosts...)
+	return &clone
+}
@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// setEnvironment merges the environments, later ones taking precedence, and
+// sends them to the session with env requests. It returns the variables the
+// server refused, which are only accepted when listed in its AcceptEnv.
+func setEnvironment(session *ssh.Session, envs ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, env := range envs {
+		for name, value := range env {
+			merged[name] = value
+		}
+	}
+
+	refused := map[string]string{}
+	for name, value := range merged {
+		if err := session.Setenv(name, value); err != nil {
+			refused[name] = value
+		}
+	}
+	return refused
+}
+
+// exportEnvironment wraps command so that it runs with env, for variables
+// that could not be sent with env requests. The names in sensitive are
+// passed over the stdin it returns, as other users on the host could read
+// them in the process list; the others are given to env(1), which works
+// whatever the login shell of the user is.
+func exportEnvironment(env map[string]string, sensitive map[string]bool, command string) (string, string) {
+	if len(env) == 0 {
+		return command, ""
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"env"}
+	var stdin strings.Builder
+	for _, name := range names {
+		if sensitive[name] {
+			fmt.Fprintf(&stdin, "export %s=%s\n", name, shellQuote(env[name]))
+			continue
+		}
+		args = append(args, name+"="+env[name])
+	}
+	if stdin.Len() > 0 {
+		command = `eval "$(cat)" || exit 1; ` + command
+	}
+	if len(args) == 1 {
+		args = nil
+	}
+	return commandLine(append(args, "sh", "-c", command)), stdin.String()
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
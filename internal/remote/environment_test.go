@@ -0,0 +1,138 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestExportEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		sensitive map[string]bool
+		want      string
+		wantStdin string
+	}{
+		{name: "empty", env: nil, want: "make"},
+		{name: "sorted", env: map[string]string{"B": "2", "A": "1"}, want: "env A=1 B=2 sh -c make"},
+		{name: "quoted", env: map[string]string{"A": "it's $HOME"}, want: `env 'A=it'\''s $HOME' sh -c make`},
+		{
+			name:      "sensitive",
+			env:       map[string]string{"A": "1", "TOKEN": "it's"},
+			sensitive: map[string]bool{"TOKEN": true},
+			want:      `env A=1 sh -c 'eval "$(cat)" || exit 1; make'`,
+			wantStdin: "export TOKEN='it'\\''s'\n",
+		},
+		{
+			name:      "sensitive only",
+			env:       map[string]string{"TOKEN": "s3cr3t"},
+			sensitive: map[string]bool{"TOKEN": true},
+			want:      `sh -c 'eval "$(cat)" || exit 1; make'`,
+			wantStdin: "export TOKEN='s3cr3t'\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stdin := exportEnvironment(tt.env, tt.sensitive, "make")
+			if got != tt.want {
+				t.Errorf("exportEnvironment() = %q, want %q", got, tt.want)
+			}
+			if stdin != tt.wantStdin {
+				t.Errorf("exportEnvironment() stdin = %q, want %q", stdin, tt.wantStdin)
+			}
+		})
+	}
+}
+
+func TestConfig_RunCommandEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		acceptEnv []string
+		config    map[string]string
+		command   Command
+		want      string
+	}{
+		{
+			name:      "env requests",
+			acceptEnv: []string{"LANG", "TOKEN"},
+			command:   Command{Command: "env", Environment: map[string]string{"LANG": "C"}, SensitiveEnvironment: map[string]string{"TOKEN": "s3cr3t"}},
+			want:      "env\nLANG=C\nTOKEN=s3cr3t\n",
+		},
+		{
+			name:    "env fallback",
+			command: Command{Command: "env", Environment: map[string]string{"LANG": "C"}},
+			want:    "env LANG=C sh -c env\nLANG=C\n",
+		},
+		{
+			name:      "stdin fallback",
+			acceptEnv: []string{"LANG"},
+			command:   Command{Command: "env", Environment: map[string]string{"LANG": "C"}, SensitiveEnvironment: map[string]string{"TOKEN": "it's"}},
+			want:      "sh -c 'eval \"$(cat)\" || exit 1; env'\nLANG=C\nTOKEN=it's\n",
+		},
+		{
+			name:      "command over config",
+			acceptEnv: []string{"LANG", "TZ"},
+			config:    map[string]string{"LANG": "en_US.UTF-8", "TZ": "UTC"},
+			command:   Command{Command: "env", Environment: map[string]string{"LANG": "C"}},
+			want:      "env\nLANG=C\nTZ=UTC\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			server.mu.Lock()
+			server.acceptEnv = tt.acceptEnv
+			server.mu.Unlock()
+			config := server.config("deploy")
+			config.Environment = tt.config
+
+			result, err := config.RunCommand(context.Background(), tt.command, 10*time.Second)
+			if err != nil {
+				t.Fatalf("Config.RunCommand() error = %v", err)
+			}
+			if result.Stdout != tt.want {
+				t.Errorf("Config.RunCommand() stdout = %q, want %q", result.Stdout, tt.want)
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			for _, command := range server.commands {
+				for _, value := range tt.command.SensitiveEnvironment {
+					if strings.Contains(command, value) {
+						t.Errorf("command line %q contains the sensitive value %q", command, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExecMasksSensitiveEnvironment(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+	config := newTestServer(t).config("deploy")
+	config.SensitiveEnvironment = map[string]string{"PROVIDER_TOKEN": "pr0v1der"}
+	commands := []Command{
+		{Command: "echo ok", SensitiveEnvironment: map[string]string{"TOKEN": "s3cr3t"}},
+		{Command: "exit 1", SensitiveEnvironment: map[string]string{"TOKEN": "s3cr3t"}},
+	}
+
+	if _, err := exec(ctx, RetryPolicy{}, commands, 10*time.Second, config); err == nil {
+		t.Fatal("exec() error = nil, want the failure of the second command")
+	}
+	// The test server refuses env requests, so the values are sent over
+	// stdin and printed back with the environment.
+	if !strings.Contains(logs.String(), "echo ok") {
+		t.Fatalf("command not logged:\n%s", logs.String())
+	}
+	for _, secret := range []string{"s3cr3t", "pr0v1der"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("logs contain %q:\n%s", secret, logs.String())
+		}
+	}
+}
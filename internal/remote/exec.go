@@ -3,42 +3,150 @@ package remote
 import (
 	"context"
 	"fmt"
-	"strings"
+	"regexp"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
-	"github.com/loafoe/easyssh-proxy/v2"
 )
 
-func exec(ctx context.Context, retryDelay time.Duration, commands []string, timeout time.Duration, ssh *easyssh.MakeConfig) (string, error) {
-	var stdout, stderr string
-	var done bool
-	var err error
+// Command is a command run by a Provisioner.
+type Command struct {
+	Command string
+	// Script, when set, is uploaded to the host and run with Interpreter
+	// instead of Command, which avoids depending on the login shell of the
+	// user. Interpreter defaults to DefaultInterpreter.
+	Script      string
+	Interpreter []string
+	// ForwardAgent forwards an agent to the command, so that it can
+	// authenticate onwards, e.g. to clone private git repositories.
+	ForwardAgent bool
+	// Environment is set for the command, over the environment of the
+	// Config. SensitiveEnvironment is set the same way, but its values are
+	// masked in logs.
+	Environment          map[string]string
+	SensitiveEnvironment map[string]string
+
+	// ExpectedExitCodes are the exit codes that count as success. Defaults
+	// to 0.
+	ExpectedExitCodes []int
+	// StdoutMustMatch fails the command when its stdout does not match.
+	StdoutMustMatch *regexp.Regexp
+	// StderrMustNotMatch fails the command when its stderr matches.
+	StderrMustNotMatch *regexp.Regexp
+	// ContinueOnError runs the following commands even when this one fails.
+	ContinueOnError bool
+}
+
+// CommandError is returned when a command ran but failed, because of its
+// exit status or its output.
+type CommandError struct {
+	Result Result
+	// Reason says why the command failed, e.g. "exited with status 1".
+	Reason string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command %q %s", e.Result.Command, e.Reason)
+}
+
+// verify checks result against the expectations of cmd.
+func (cmd *Command) verify(result *Result) error {
+	expected := cmd.ExpectedExitCodes
+	if len(expected) == 0 {
+		expected = []int{0}
+	}
+	ok := false
+	for _, code := range expected {
+		ok = ok || code == result.ExitCode
+	}
+
+	switch {
+	case !ok && result.ExitSignal != "":
+		return &CommandError{Result: *result, Reason: "was terminated by signal " + result.ExitSignal}
+	case !ok:
+		return &CommandError{Result: *result, Reason: fmt.Sprintf("exited with status %d", result.ExitCode)}
+	case cmd.StdoutMustMatch != nil && !cmd.StdoutMustMatch.MatchString(result.Stdout):
+		return &CommandError{Result: *result, Reason: fmt.Sprintf("printed stdout not matching %q", cmd.StdoutMustMatch)}
+	case cmd.StderrMustNotMatch != nil && cmd.StderrMustNotMatch.MatchString(result.Stderr):
+		return &CommandError{Result: *result, Reason: fmt.Sprintf("printed stderr matching %q", cmd.StderrMustNotMatch)}
+	}
+	return nil
+}
+
+// Result describes a command run on the host.
+type Result struct {
+	Command string
+	Stdout  string
+	Stderr  string
+	// ExitCode is -1 when the command did not report an exit status.
+	ExitCode int
+	// ExitSignal is the signal that terminated the command, e.g. KILL.
+	ExitSignal string
+	Start      time.Time
+	Duration   time.Duration
+}
+
+func exec(ctx context.Context, retry RetryPolicy, commands []Command, timeout time.Duration, ssh *Config) ([]Result, error) {
+	results := make([]Result, 0, len(commands))
 
 	for i := 0; i < len(commands); i++ {
-		for {
-			stdout, stderr, done, err = ssh.Run(commands[i], timeout)
-			tflog.Debug(ctx, commands[i], map[string]interface{}{"done": done, "stdout": stdout, "stderr": stderr, "error": err})
+		// Sensitive values may be echoed by the command or end up in
+		// errors, so mask them in everything logged about it.
+		ctx := tflog.MaskLogStrings(ctx, sensitiveValues(ssh.SensitiveEnvironment, commands[i].SensitiveEnvironment)...)
+
+		for attempt := 1; ; attempt++ {
+			result, err := ssh.RunCommand(ctx, commands[i], timeout)
+			tflog.Debug(ctx, result.Command, map[string]interface{}{"stdout": result.Stdout, "stderr": result.Stderr, "exit_code": result.ExitCode, "attempt": attempt, "error": errorString(err)})
+
+			// The command ran, so its exit status and output decide whether
+			// it succeeded.
+			if err == nil || isExitError(err) {
+				err = commands[i].verify(result)
+			}
 			if err == nil {
+				results = append(results, *result)
 				break
 			}
-			if strings.Contains(err.Error(), "no supported methods remain") {
-				return stdout, err
+
+			if retry.retries(err, attempt) {
+				tflog.Warn(ctx, fmt.Sprintf("Retrying command '%s' after %s error: %s", result.Command, Classify(err), err))
+				waitErr := retry.wait(ctx, attempt)
+				if waitErr == nil {
+					continue
+				}
+				tflog.Error(ctx, fmt.Sprintf("execution of command '%s' failed: %s: %s", result.Command, waitErr, err))
+				return append(results, *result), fmt.Errorf("%s: %w", waitErr, err)
 			}
 
-			select {
-			case <-time.After(retryDelay):
-				// Retry
+			results = append(results, *result)
+			if kind := Classify(err); commands[i].ContinueOnError && (kind == ErrorCommand || kind == ErrorTimeout) {
+				tflog.Warn(ctx, "Continuing after failed command: "+err.Error())
+				break
+			}
+			return results, err
+		}
+	}
+	return results, nil
+}
 
-			case <-ctx.Done():
-				tflog.Debug(ctx, fmt.Sprintf("error: %v\n", err))
-				tflog.Error(ctx, fmt.Sprintf("execution of command '%s' failed: %s: %s", commands[i], ctx.Err(), err))
-				if stderr != "" {
-					return stdout, fmt.Errorf("stderr output: %s", stderr)
-				}
-				return stdout, err
+// sensitiveValues returns the non-empty values of the environments.
+func sensitiveValues(envs ...map[string]string) []string {
+	var values []string
+	for _, env := range envs {
+		for _, value := range env {
+			if value != "" {
+				values = append(values, value)
 			}
 		}
 	}
-	return stdout, nil
+	return values
+}
+
+// errorString returns the message of err, which unlike err itself is masked
+// in logs.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
@@ -0,0 +1,96 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestProvisioner_ExecuteExpectations(t *testing.T) {
+	tests := []struct {
+		name        string
+		commands    []Command
+		wantResults int
+		wantReason  string
+	}{
+		{
+			name:        "success",
+			commands:    []Command{{Command: "true"}},
+			wantResults: 1,
+		},
+		{
+			name:        "unexpected exit status",
+			commands:    []Command{{Command: "exit 3"}, {Command: "true"}},
+			wantResults: 1,
+			wantReason:  "exited with status 3",
+		},
+		{
+			name:        "expected exit status",
+			commands:    []Command{{Command: "exit 3", ExpectedExitCodes: []int{0, 3}}},
+			wantResults: 1,
+		},
+		{
+			name:        "zero not expected",
+			commands:    []Command{{Command: "true", ExpectedExitCodes: []int{1}}},
+			wantResults: 1,
+			wantReason:  "exited with status 0",
+		},
+		{
+			name:        "signal",
+			commands:    []Command{{Command: "kill TERM"}},
+			wantResults: 1,
+			wantReason:  "was terminated by signal TERM",
+		},
+		{
+			name:        "stdout matches",
+			commands:    []Command{{Command: "hostname", StdoutMustMatch: regexp.MustCompile(`^host`)}},
+			wantResults: 1,
+		},
+		{
+			name:        "stdout does not match",
+			commands:    []Command{{Command: "hostname", StdoutMustMatch: regexp.MustCompile(`^ok$`)}},
+			wantResults: 1,
+			wantReason:  `printed stdout not matching "^ok$"`,
+		},
+		{
+			name:        "stderr matches",
+			commands:    []Command{{Command: "exit 3", ExpectedExitCodes: []int{3}, StderrMustNotMatch: regexp.MustCompile(`exiting`)}},
+			wantResults: 1,
+			wantReason:  `printed stderr matching "exiting"`,
+		},
+		{
+			name:        "continue on error",
+			commands:    []Command{{Command: "exit 1", ContinueOnError: true}, {Command: "true"}},
+			wantResults: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			p := NewProvisioner(server.config("deploy"), 10*time.Second, time.Millisecond)
+
+			results, err := p.Execute(tt.commands, context.Background())
+			var cmdErr *CommandError
+			switch {
+			case tt.wantReason == "" && err != nil:
+				t.Fatalf("Execute() error = %v", err)
+			case tt.wantReason != "" && !errors.As(err, &cmdErr):
+				t.Fatalf("Execute() error = %v, want *CommandError", err)
+			case tt.wantReason != "" && cmdErr.Reason != tt.wantReason:
+				t.Errorf("CommandError.Reason = %q, want %q", cmdErr.Reason, tt.wantReason)
+			}
+			if len(results) != tt.wantResults {
+				t.Errorf("Execute() returned %d results, want %d", len(results), tt.wantResults)
+			}
+			// Failed commands are not retried.
+			server.mu.Lock()
+			ran := len(server.commands)
+			server.mu.Unlock()
+			if ran != tt.wantResults {
+				t.Errorf("server ran %d commands, want %d", ran, tt.wantResults)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package remote
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// forwardedAgents records the connections that already serve
+// auth-agent@openssh.com channels, which can only be handled once per
+// connection.
+var forwardedAgents sync.Map
+
+// forwardAgent makes an agent available to sessions on client that request
+// agent forwarding. The local agent is forwarded when Agent is set,
+// otherwise an in-memory agent holding PrivateKey.
+func (c *Config) forwardAgent(client *ssh.Client) error {
+	if _, loaded := forwardedAgents.LoadOrStore(client, struct{}{}); loaded {
+		return nil
+	}
+
+	var err error
+	switch {
+	case c.Agent:
+		socket := c.AgentSocket
+		if socket == "" {
+			socket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if socket == "" {
+			err = fmt.Errorf("agent forwarding requested but no agent socket is configured and SSH_AUTH_SOCK is not set")
+			break
+		}
+		err = agent.ForwardToRemote(client, ExpandPath(socket))
+	case c.PrivateKey != "":
+		var key interface{}
+		if key, err = parseRawPrivateKey([]byte(c.PrivateKey), c.Passphrase); err != nil {
+			break
+		}
+		keyring := agent.NewKeyring()
+		if err = keyring.Add(agent.AddedKey{PrivateKey: key, Comment: "terraform-provider-ssh"}); err != nil {
+			break
+		}
+		err = agent.ForwardToAgent(client, keyring)
+	default:
+		err = fmt.Errorf("agent forwarding requires agent or private_key to be configured")
+	}
+	if err != nil {
+		forwardedAgents.Delete(client)
+		return err
+	}
+
+	go func() {
+		_ = client.Wait()
+		forwardedAgents.Delete(client)
+	}()
+	return nil
+}
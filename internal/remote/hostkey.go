@@ -0,0 +1,188 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyConfig describes how server host keys are verified. A key is accepted
+// when it matches one of the pinned fingerprints or a known_hosts entry,
+// including @cert-authority lines and hashed hostnames.
+type HostKeyConfig struct {
+	// KnownHosts holds known_hosts formatted content.
+	KnownHosts []string
+	// KnownHostsFiles lists paths to known_hosts files.
+	KnownHostsFiles []string
+	// Fingerprints lists SHA256 fingerprints, e.g. SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8.
+	Fingerprints []string
+}
+
+// HostKeyError reports a host key that could not be verified.
+type HostKeyError struct {
+	Host        string
+	KeyType     string
+	Fingerprint string
+	Reason      string
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf("host key verification failed for %s: %s key %s %s", e.Host, e.KeyType, e.Fingerprint, e.Reason)
+}
+
+// IsEmpty reports whether no host key verification has been configured.
+func (h HostKeyConfig) IsEmpty() bool {
+	return len(h.KnownHosts) == 0 && len(h.KnownHostsFiles) == 0 && len(h.Fingerprints) == 0
+}
+
+// Callback builds a host key callback enforcing the configuration. When no
+// verification is configured every host key is accepted.
+func (h HostKeyConfig) Callback() (ssh.HostKeyCallback, error) {
+	if h.IsEmpty() {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	pins := make(map[string]bool, len(h.Fingerprints))
+	for _, f := range h.Fingerprints {
+		pins[normalizeFingerprint(f)] = true
+	}
+
+	knownHosts, err := h.knownHostsCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if pins[fingerprint] {
+			return nil
+		}
+		if cert, ok := key.(*ssh.Certificate); ok && pins[ssh.FingerprintSHA256(cert.Key)] {
+			return nil
+		}
+
+		keyErr := &HostKeyError{
+			Host:        hostname,
+			KeyType:     key.Type(),
+			Fingerprint: fingerprint,
+			Reason:      "does not match any pinned fingerprint",
+		}
+		if knownHosts == nil {
+			return keyErr
+		}
+
+		err := knownHosts(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var mismatch *knownhosts.KeyError
+		var revoked *knownhosts.RevokedError
+		switch {
+		case errors.As(err, &mismatch) && len(mismatch.Want) == 0:
+			keyErr.Reason = "is not listed in known_hosts"
+		case errors.As(err, &mismatch):
+			want := mismatch.Want[0]
+			keyErr.Reason = fmt.Sprintf("does not match the known_hosts entry at %s:%d (%s)", want.Filename, want.Line, ssh.FingerprintSHA256(want.Key))
+		case errors.As(err, &revoked):
+			keyErr.Reason = fmt.Sprintf("has been revoked at %s:%d", revoked.Revoked.Filename, revoked.Revoked.Line)
+		default:
+			keyErr.Reason = err.Error()
+		}
+		return keyErr
+	}, nil
+}
+
+func (h HostKeyConfig) knownHostsCallback() (ssh.HostKeyCallback, error) {
+	files := make([]string, 0, len(h.KnownHostsFiles)+len(h.KnownHosts))
+	for _, f := range h.KnownHostsFiles {
+		files = append(files, ExpandPath(f))
+	}
+
+	// knownhosts only reads from files, so inline content is staged in a
+	// temporary file for the duration of the parse.
+	for _, content := range h.KnownHosts {
+		tmp, err := os.CreateTemp("", "known_hosts")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.WriteString(content)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tmp.Name())
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimRight(strings.TrimSpace(fingerprint), "=")
+	if !strings.HasPrefix(fingerprint, "SHA256:") {
+		fingerprint = "SHA256:" + fingerprint
+	}
+	return fingerprint
+}
+
+// ExpandPath replaces a leading ~ with the current user's home directory.
+func ExpandPath(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
+
+// ScanHostKey connects to the host, verifying its key as configured, and
+// returns the SHA256 fingerprint of the key it presented. For a host
+// certificate the fingerprint of the certified key is returned, so that
+// renewing the certificate does not change it.
+func (c *Config) ScanHostKey(ctx context.Context) (string, error) {
+	scan := c.Clone()
+	scan.Pool = nil
+
+	var fingerprint string
+	scan.hostKeyObserver = func(key ssh.PublicKey) {
+		if cert, ok := key.(*ssh.Certificate); ok {
+			key = cert.Key
+		}
+		fingerprint = ssh.FingerprintSHA256(key)
+	}
+
+	client, err := scan.Connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	closeClient(client)
+	return fingerprint, nil
+}
+
+// PinHostKey returns a copy of c that only accepts the host key with the
+// given fingerprint, e.g. one recorded on first use.
+func (c *Config) PinHostKey(fingerprint string) *Config {
+	pinned := c.Clone()
+	pinned.HostKeys = HostKeyConfig{Fingerprints: []string{fingerprint}}
+	return pinned
+}
@@ -0,0 +1,128 @@
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHostKeyConfig_Callback(t *testing.T) {
+	key := newTestPublicKey(t)
+	other := newTestPublicKey(t)
+	remoteAddr := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	tests := []struct {
+		name       string
+		hostKeys   HostKeyConfig
+		hostname   string
+		wantErr    bool
+		wantReason string
+	}{
+		{name: "empty", hostKeys: HostKeyConfig{}, hostname: "example.com:22"},
+		{name: "pinned", hostKeys: HostKeyConfig{Fingerprints: []string{ssh.FingerprintSHA256(key)}}, hostname: "example.com:22"},
+		{name: "pinned without prefix", hostKeys: HostKeyConfig{Fingerprints: []string{strings.TrimPrefix(ssh.FingerprintSHA256(key), "SHA256:") + "="}}, hostname: "example.com:22"},
+		{name: "pin mismatch", hostKeys: HostKeyConfig{Fingerprints: []string{ssh.FingerprintSHA256(other)}}, hostname: "example.com:22", wantErr: true, wantReason: "does not match any pinned fingerprint"},
+		{name: "known host", hostKeys: HostKeyConfig{KnownHosts: []string{knownhosts.Line([]string{"example.com"}, key)}}, hostname: "example.com:22"},
+		{name: "hashed known host", hostKeys: HostKeyConfig{KnownHosts: []string{knownhosts.Line([]string{knownhosts.HashHostname("example.com")}, key)}}, hostname: "example.com:22"},
+		{name: "known host mismatch", hostKeys: HostKeyConfig{KnownHosts: []string{knownhosts.Line([]string{"example.com"}, other)}}, hostname: "example.com:22", wantErr: true, wantReason: "does not match the known_hosts entry"},
+		{name: "unknown host", hostKeys: HostKeyConfig{KnownHosts: []string{knownhosts.Line([]string{"other.example.com"}, other)}}, hostname: "example.com:22", wantErr: true, wantReason: "is not listed in known_hosts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := tt.hostKeys.Callback()
+			if err != nil {
+				t.Fatalf("HostKeyConfig.Callback() error = %v", err)
+			}
+			err = callback(tt.hostname, remoteAddr, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("callback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			var hostKeyErr *HostKeyError
+			if !errors.As(err, &hostKeyErr) {
+				t.Fatalf("callback() error = %T, want *HostKeyError", err)
+			}
+			if !strings.Contains(hostKeyErr.Reason, tt.wantReason) {
+				t.Errorf("callback() reason = %q, want %q", hostKeyErr.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestHostKeyConfig_CallbackCertAuthority(t *testing.T) {
+	caPub, caPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, _ := ssh.NewPublicKey(caPub)
+
+	cert := &ssh.Certificate{
+		Key:             newTestPublicKey(t),
+		CertType:        ssh.HostCert,
+		ValidPrincipals: []string{"example.com"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	hostKeys := HostKeyConfig{KnownHosts: []string{"@cert-authority *.com " + string(ssh.MarshalAuthorizedKey(caKey))}}
+	callback, err := hostKeys.Callback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := callback("example.com:22", &net.TCPAddr{}, cert); err != nil {
+		t.Errorf("callback() error = %v", err)
+	}
+}
+
+func TestConfig_ScanHostKey(t *testing.T) {
+	server := newTestServer(t)
+
+	config := server.config("alice")
+	config.HostKeys = HostKeyConfig{}
+
+	fingerprint, err := config.ScanHostKey(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ssh.FingerprintSHA256(server.hostKey.PublicKey()); fingerprint != want {
+		t.Errorf("ScanHostKey() = %s, want %s", fingerprint, want)
+	}
+
+	if _, _, err := config.PinHostKey(fingerprint).Run(context.Background(), "true", 0); err != nil {
+		t.Errorf("pinned host key rejected: %v", err)
+	}
+
+	_, _, err = config.PinHostKey(ssh.FingerprintSHA256(newTestPublicKey(t))).Run(context.Background(), "true", 0)
+	var hostKeyErr *HostKeyError
+	if !errors.As(err, &hostKeyErr) {
+		t.Errorf("expected HostKeyError for a changed host key, got %v", err)
+	}
+}
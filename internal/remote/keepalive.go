@@ -0,0 +1,94 @@
+package remote
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeepaliveError is returned by operations on a connection that was closed
+// because the server stopped answering keepalive requests.
+type KeepaliveError struct {
+	Host     string
+	Missed   int
+	Interval time.Duration
+}
+
+func (e *KeepaliveError) Error() string {
+	return fmt.Sprintf("connection to %s declared dead after %d keepalive requests sent every %s went unanswered", e.Host, e.Missed, e.Interval)
+}
+
+// deadConnections records the KeepaliveError of each connection closed by
+// keepalive, so that operations failing on it can report why. Entries are
+// removed by closeClient once the owner of the connection lets go of it.
+var deadConnections sync.Map
+
+// closeClient closes client and forgets why keepalive closed it, if it did.
+// Errors of the connection must be passed to keepaliveError before.
+func closeClient(client *ssh.Client) {
+	client.Close()
+	deadConnections.Delete(client)
+}
+
+// keepalive sends keepalive@openssh.com requests every interval, like
+// OpenSSH's ServerAliveInterval, and closes the connection once maxMissed
+// requests in a row went unanswered.
+func keepalive(client *ssh.Client, host string, interval time.Duration, maxMissed int) {
+	if maxMissed <= 0 {
+		maxMissed = 3
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	replies := make(chan error, 1)
+	pending := false
+	missed := 0
+	for {
+		select {
+		case <-closed:
+			return
+		case err := <-replies:
+			if err != nil {
+				return
+			}
+			pending, missed = false, 0
+		case <-ticker.C:
+			if pending {
+				missed++
+				if missed >= maxMissed {
+					deadConnections.Store(client, &KeepaliveError{Host: host, Missed: missed, Interval: interval})
+					client.Close()
+					return
+				}
+				continue
+			}
+			pending = true
+			go func() {
+				// Any reply, including a failure, shows the server is alive.
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				replies <- err
+			}()
+		}
+	}
+}
+
+// keepaliveError returns the KeepaliveError for client, or err when the
+// connection was not closed by keepalive.
+func keepaliveError(client *ssh.Client, err error) error {
+	if err == nil {
+		return nil
+	}
+	if dead, ok := deadConnections.Load(client); ok {
+		return fmt.Errorf("%w: %v", dead.(*KeepaliveError), err)
+	}
+	return err
+}
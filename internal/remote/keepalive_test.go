@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeepalive(t *testing.T) {
+	tests := []struct {
+		name           string
+		ignoreRequests bool
+		wantDead       bool
+	}{
+		{name: "answered", ignoreRequests: false, wantDead: false},
+		{name: "unanswered", ignoreRequests: true, wantDead: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			server.mu.Lock()
+			server.ignoreRequests = tt.ignoreRequests
+			server.mu.Unlock()
+
+			config := server.config("alice")
+			config.KeepaliveInterval = 10 * time.Millisecond
+			config.KeepaliveMaxMissed = 2
+
+			client, err := config.Connect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			closed := make(chan struct{})
+			go func() {
+				_ = client.Wait()
+				close(closed)
+			}()
+
+			select {
+			case <-closed:
+				if !tt.wantDead {
+					t.Fatal("connection closed although keepalives were answered")
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.wantDead {
+					t.Fatal("connection was not declared dead")
+				}
+				return
+			}
+
+			_, err = client.NewSession()
+			var keepaliveErr *KeepaliveError
+			if !errors.As(keepaliveError(client, err), &keepaliveErr) {
+				t.Errorf("expected KeepaliveError, got %v", err)
+			}
+
+			closeClient(client)
+			if _, ok := deadConnections.Load(client); ok {
+				t.Error("KeepaliveError still recorded after the connection was closed")
+			}
+		})
+	}
+}
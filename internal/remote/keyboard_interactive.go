@@ -0,0 +1,84 @@
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PromptResponse answers keyboard-interactive prompts matching Pattern,
+// either with a fixed Answer or with a TOTP code generated from TOTPSecret.
+type PromptResponse struct {
+	Pattern    *regexp.Regexp
+	Answer     string
+	TOTPSecret string
+}
+
+func (r PromptResponse) answer(now time.Time) (string, error) {
+	if r.TOTPSecret != "" {
+		return TOTP(r.TOTPSecret, now)
+	}
+	return r.Answer, nil
+}
+
+// keyboardInteractive answers each prompt with the first matching response.
+func keyboardInteractive(responses []PromptResponse) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			matched := false
+			for _, r := range responses {
+				if !r.Pattern.MatchString(question) {
+					continue
+				}
+				answer, err := r.answer(time.Now())
+				if err != nil {
+					return nil, err
+				}
+				answers[i], matched = answer, true
+				break
+			}
+			if !matched {
+				return nil, fmt.Errorf("no keyboard-interactive answer configured for prompt %q", strings.TrimSpace(question))
+			}
+		}
+		return answers, nil
+	}
+}
+
+// TOTP generates an RFC 6238 code with the default parameters used by
+// authenticator apps: HMAC-SHA1, a 30 second step and 6 digits. The secret
+// is base32 encoded, as found in otpauth:// URIs.
+func TOTP(secret string, now time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(now.Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000), nil
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 TOTP secret: %w", err)
+	}
+	return key, nil
+}
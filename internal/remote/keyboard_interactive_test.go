@@ -0,0 +1,58 @@
+package remote
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B test vectors for HMAC-SHA1, truncated to 6 digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "59", unix: 59, want: "287082"},
+		{name: "1111111109", unix: 1111111109, want: "081804"},
+		{name: "1234567890", unix: 1234567890, want: "005924"},
+		{name: "2000000000", unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTP(secret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := TOTP("not base32!", time.Now()); err == nil {
+		t.Error("expected an error for an invalid secret")
+	}
+}
+
+func TestKeyboardInteractive(t *testing.T) {
+	challenge := keyboardInteractive([]PromptResponse{
+		{Pattern: regexp.MustCompile(`(?i)password`), Answer: "secret"},
+		{Pattern: regexp.MustCompile(`(?i)verification code`), TOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+	})
+
+	answers, err := challenge("", "", []string{"Password: ", "Verification code: "}, []bool{false, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answers[0] != "secret" || len(answers[1]) != 6 {
+		t.Errorf("unexpected answers %q", answers)
+	}
+
+	if _, err := challenge("", "", []string{"PIN: "}, []bool{false}); err == nil {
+		t.Error("expected an error for an unmatched prompt")
+	}
+}
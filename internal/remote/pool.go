@@ -0,0 +1,215 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Pool shares SSH connections between operations on the same host with the
+// same credentials. Each operation opens its own session on the shared
+// connection. Connections are closed once they have been idle for
+// IdleTimeout, and connections that have died are replaced.
+type Pool struct {
+	IdleTimeout time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+}
+
+type poolEntry struct {
+	// mu serialises dialing so that concurrent operations on a new host
+	// share one handshake.
+	mu     sync.Mutex
+	client *ssh.Client
+	dead   chan struct{}
+	refs   int
+	idle   *time.Timer
+	// stale holds the references to discarded connections still used by
+	// other operations. They are closed once the last one is released.
+	stale map[*ssh.Client]int
+}
+
+// NewPool returns an empty pool that closes connections idle for idleTimeout.
+func NewPool(idleTimeout time.Duration) *Pool {
+	return &Pool{
+		IdleTimeout: idleTimeout,
+		entries:     make(map[string]*poolEntry),
+	}
+}
+
+// Get returns a connection for c, dialing one when there is no live
+// connection in the pool. release must be called once the connection is no
+// longer used.
+func (p *Pool) Get(ctx context.Context, c *Config) (client *ssh.Client, release func(), err error) {
+	key := c.poolKey()
+
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if !ok {
+		e = &poolEntry{}
+		p.entries[key] = e
+	}
+	p.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client != nil && !e.closed() {
+		if e.idle != nil {
+			e.idle.Stop()
+			e.idle = nil
+		}
+	} else {
+		client, err := c.Connect(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Operations still holding a replaced connection no longer count.
+		if e.client != nil {
+			closeClient(e.client)
+		}
+		e.client = client
+		e.refs = 0
+		e.dead = make(chan struct{})
+		go func(dead chan struct{}) {
+			_ = client.Wait()
+			close(dead)
+		}(e.dead)
+	}
+
+	e.refs++
+	client = e.client
+	var once sync.Once
+	return client, func() { once.Do(func() { p.release(e, client) }) }, nil
+}
+
+// Discard removes a connection that failed from the pool, so that the next
+// Get dials a new one. It is closed once the operations still using it have
+// released it.
+func (p *Pool) Discard(client *ssh.Client) {
+	p.mu.Lock()
+	entries := make([]*poolEntry, 0, len(p.entries))
+	for _, e := range p.entries {
+		entries = append(entries, e)
+	}
+	p.mu.Unlock()
+
+	for _, e := range entries {
+		e.mu.Lock()
+		if _, ok := e.stale[client]; ok {
+			e.mu.Unlock()
+			return
+		}
+		if e.client == client {
+			e.client = nil
+			if e.refs > 0 {
+				if e.stale == nil {
+					e.stale = map[*ssh.Client]int{}
+				}
+				e.stale[client] = e.refs
+				e.mu.Unlock()
+				return
+			}
+		}
+		e.mu.Unlock()
+	}
+	closeClient(client)
+}
+
+// Close closes every connection in the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, e := range p.entries {
+		e.mu.Lock()
+		if e.client != nil {
+			closeClient(e.client)
+			e.client = nil
+		}
+		for client := range e.stale {
+			closeClient(client)
+		}
+		e.stale = nil
+		e.mu.Unlock()
+		delete(p.entries, key)
+	}
+	return nil
+}
+
+func (p *Pool) release(e *poolEntry, client *ssh.Client) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != client {
+		if refs, ok := e.stale[client]; ok {
+			if refs > 1 {
+				e.stale[client] = refs - 1
+			} else {
+				delete(e.stale, client)
+				closeClient(client)
+			}
+		}
+		return
+	}
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+	e.idle = time.AfterFunc(p.IdleTimeout, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.refs == 0 && e.client == client {
+			closeClient(client)
+			e.client = nil
+		}
+	})
+}
+
+func (e *poolEntry) closed() bool {
+	select {
+	case <-e.dead:
+		return true
+	default:
+		return false
+	}
+}
+
+// poolKey identifies the endpoint and credentials of a connection. Secrets
+// are hashed rather than kept in the key.
+func (c *Config) poolKey() string {
+	h := sha256.New()
+	c.writeKey(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Config) writeKey(h hash.Hash) {
+	fmt.Fprintf(h, "%q %q %q %q %q %q %q\n", c.Address(), c.User, c.Password, c.PrivateKey, c.Passphrase, c.Certificate, c.IdentityFiles)
+	for _, s := range c.Signers {
+		// Renewed certificates certify the same key, and keep using the
+		// connections made with the certificates they replace.
+		key := s.PublicKey()
+		if cert, ok := key.(*ssh.Certificate); ok {
+			key = cert.Key
+		}
+		h.Write(key.Marshal())
+	}
+	for _, r := range c.KeyboardInteractive {
+		fmt.Fprintf(h, "%q %q %q\n", r.Pattern, r.Answer, r.TOTPSecret)
+	}
+	fmt.Fprintf(h, "%t %q %q\n", c.Agent, c.AgentSocket, c.AgentIdentity)
+	fmt.Fprintf(h, "%q %q %q\n", c.HostKeys.KnownHosts, c.HostKeys.KnownHostsFiles, c.HostKeys.Fingerprints)
+	fmt.Fprintf(h, "%q %q %q %q\n", c.Algorithms.Ciphers, c.Algorithms.KeyExchanges, c.Algorithms.MACs, c.Algorithms.HostKeyAlgorithms)
+	if c.Proxy != nil {
+		fmt.Fprintf(h, "%q %q %q %q\n", c.Proxy.URL, c.Proxy.Username, c.Proxy.Password, c.Proxy.NoProxy)
+	}
+	fmt.Fprintf(h, "%q %d\n", c.ProxyCommand, len(c.JumpHosts))
+	for _, hop := range c.JumpHosts {
+		hop.writeKey(h)
+	}
+}
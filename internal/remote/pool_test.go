@@ -0,0 +1,180 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolReusesConnections(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool(time.Minute)
+	defer pool.Close()
+
+	config := server.config("alice")
+	config.Pool = pool
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := config.Run(context.Background(), "true", 0); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := server.connections(); got != 1 {
+		t.Errorf("connections = %d, want 1", got)
+	}
+
+	other := server.config("bob")
+	other.Pool = pool
+	if _, _, err := other.Run(context.Background(), "true", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.connections(); got != 2 {
+		t.Errorf("connections = %d, want a second connection for another user", got)
+	}
+}
+
+func TestPoolReplacesDeadConnections(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool(time.Minute)
+	defer pool.Close()
+
+	config := server.config("alice")
+	config.Pool = pool
+
+	client, release, err := pool.Get(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	// Simulate the connection being dropped, e.g. by a NAT gateway.
+	client.Close()
+
+	if _, _, err := config.Run(context.Background(), "true", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.connections(); got != 2 {
+		t.Errorf("connections = %d, want 2", got)
+	}
+}
+
+func TestPoolEvictsIdleConnections(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool(10 * time.Millisecond)
+	defer pool.Close()
+
+	config := server.config("alice")
+	config.Pool = pool
+
+	client, release, err := pool.Get(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+}
+
+func TestPoolKeepsConnectionsWhenSessionsAreRefused(t *testing.T) {
+	server := newTestServer(t)
+	server.mu.Lock()
+	server.maxSessions = 1
+	server.mu.Unlock()
+	pool := NewPool(time.Minute)
+	defer pool.Close()
+
+	config := server.config("alice")
+	config.Pool = pool
+
+	running := make(chan error, 1)
+	go func() {
+		_, _, err := config.Run(context.Background(), "sleep 300ms", 0)
+		running <- err
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		server.mu.Lock()
+		started := len(server.commands) > 0
+		server.mu.Unlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("command did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, _, err := config.Run(context.Background(), "true", 0)
+	if kind := Classify(err); kind != ErrorSession {
+		t.Errorf("refused session error = %v of kind %q, want %q", err, kind, ErrorSession)
+	}
+	if err := <-running; err != nil {
+		t.Errorf("running command failed after a session was refused: %v", err)
+	}
+	if _, _, err := config.Run(context.Background(), "true", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.connections(); got != 1 {
+		t.Errorf("connections = %d, want the connection to be kept", got)
+	}
+}
+
+func TestPoolDiscardWaitsForRelease(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool(time.Minute)
+	defer pool.Close()
+
+	config := server.config("alice")
+	config.Pool = pool
+
+	client, release, err := pool.Get(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, releaseOther, err := pool.Get(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != client {
+		t.Fatal("pool did not share the connection")
+	}
+
+	releaseOther()
+	pool.Discard(client)
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("discarded connection was closed while in use: %v", err)
+	}
+	session.Close()
+
+	replaced, releaseReplaced, err := pool.Get(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseReplaced()
+	if replaced == client {
+		t.Error("Get returned the discarded connection")
+	}
+
+	release()
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("discarded connection was not closed once released")
+	}
+}
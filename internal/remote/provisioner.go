@@ -2,29 +2,130 @@ package remote
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/loafoe/easyssh-proxy/v2"
 )
 
 type Provisioner struct {
-	Ssh        *easyssh.MakeConfig
-	Timeout    time.Duration
-	RetryDelay time.Duration
+	Ssh     *Config
+	Timeout time.Duration
+	Retry   RetryPolicy
+
+	// factory builds Ssh on first use when the provisioner is lazy.
+	factory func(ctx context.Context) (*Config, error)
+	once    sync.Once
+	err     error
+}
+
+// UnresolvedError is returned when a connection is needed but its settings
+// were still unknown when the provider was configured.
+type UnresolvedError struct {
+	Attributes []string
+}
+
+func (e *UnresolvedError) Error() string {
+	return fmt.Sprintf("the connection settings %s are not known yet; they must be resolved before the provider can connect", strings.Join(e.Attributes, ", "))
+}
+
+// Connection returns the connection settings, building them on first use
+// for a lazy provisioner.
+func (p *Provisioner) Connection(ctx context.Context) (*Config, error) {
+	p.once.Do(func() {
+		if p.factory != nil && p.Ssh == nil {
+			p.Ssh, p.err = p.factory(ctx)
+		}
+	})
+	return p.Ssh, p.err
 }
 
-func (p *Provisioner) Execute(commands []string, ctx context.Context) (string, error) {
-	return exec(ctx, p.RetryDelay, commands, p.Timeout, p.Ssh)
+// Execute runs the commands in order, stopping at the first that fails. The
+// results of the commands that ran are returned, including the failed one.
+func (p *Provisioner) Execute(commands []Command, ctx context.Context) ([]Result, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	ssh, err := p.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return exec(ctx, p.Retry, commands, p.Timeout, ssh)
 }
 
 func (p *Provisioner) CopyFiles(files []File, ctx context.Context) error {
-	return copyFiles(ctx, p.RetryDelay, p.Ssh, files)
+	if len(files) == 0 {
+		return nil
+	}
+	ssh, err := p.Connection(ctx)
+	if err != nil {
+		return err
+	}
+	return copyFiles(ctx, p.Retry, ssh, files)
+}
+
+// Forward opens the remote forwards on the host. They stay open until the
+// returned Forwarding is closed.
+func (p *Provisioner) Forward(forwards []RemoteForward, ctx context.Context) (*Forwarding, error) {
+	if len(forwards) == 0 {
+		return &Forwarding{}, nil
+	}
+	ssh, err := p.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.Forward(ctx, forwards)
+}
+
+// Tunnel opens a tunnel from localAddress to remoteAddress through the host.
+func (p *Provisioner) Tunnel(localAddress, remoteAddress string, ctx context.Context) (*Tunnel, error) {
+	ssh, err := p.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.Tunnel(ctx, localAddress, remoteAddress)
+}
+
+// ScanHostKey returns the fingerprint of the host key presented by the host.
+func (p *Provisioner) ScanHostKey(ctx context.Context) (string, error) {
+	ssh, err := p.Connection(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ssh.ScanHostKey(ctx)
+}
+
+// PinHostKey returns a provisioner that only accepts the host key with the
+// given fingerprint.
+func (p *Provisioner) PinHostKey(ctx context.Context, fingerprint string) (*Provisioner, error) {
+	ssh, err := p.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Provisioner{Ssh: ssh.PinHostKey(fingerprint), Timeout: p.Timeout, Retry: p.Retry}, nil
+}
+
+// WithRetry returns a provisioner for the same connection that retries
+// failed operations according to retry.
+func (p *Provisioner) WithRetry(retry RetryPolicy) *Provisioner {
+	return &Provisioner{factory: p.Connection, Timeout: p.Timeout, Retry: retry}
+}
+
+func NewProvisioner(ssh *Config, timeout time.Duration, retryDelay time.Duration) *Provisioner {
+	return &Provisioner{
+		Ssh:     ssh,
+		Timeout: timeout,
+		Retry:   RetryPolicy{Delay: retryDelay},
+	}
 }
 
-func NewProvisioner(ssh *easyssh.MakeConfig, timeout time.Duration, retryDelay time.Duration) *Provisioner {
+// NewLazyProvisioner returns a provisioner that calls factory the first time
+// a connection is needed, so that settings unknown when the provider is
+// configured do not fail plans that never connect.
+func NewLazyProvisioner(factory func(ctx context.Context) (*Config, error), timeout time.Duration, retryDelay time.Duration) *Provisioner {
 	return &Provisioner{
-		Ssh:        ssh,
-		Timeout:    timeout,
-		RetryDelay: retryDelay,
+		factory: factory,
+		Timeout: timeout,
+		Retry:   RetryPolicy{Delay: retryDelay},
 	}
 }
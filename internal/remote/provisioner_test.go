@@ -0,0 +1,51 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLazyProvisioner(t *testing.T) {
+	server := newTestServer(t)
+
+	calls := 0
+	p := NewLazyProvisioner(func(context.Context) (*Config, error) {
+		calls++
+		return server.config("alice"), nil
+	}, time.Second, time.Millisecond)
+
+	if _, err := p.Execute(nil, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Fatalf("factory called %d times without any commands", calls)
+	}
+
+	for i := 0; i < 2; i++ {
+		results, err := p.Execute([]Command{{Command: "hostname"}}, context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Stdout != "hostname\n" {
+			t.Errorf("Execute() = %+v", results)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("factory called %d times, want 1", calls)
+	}
+}
+
+func TestLazyProvisionerUnresolved(t *testing.T) {
+	p := NewLazyProvisioner(func(context.Context) (*Config, error) {
+		return nil, &UnresolvedError{Attributes: []string{"host"}}
+	}, time.Second, time.Millisecond)
+
+	_, err := p.Execute([]Command{{Command: "true"}}, context.Background())
+
+	var unresolved *UnresolvedError
+	if !errors.As(err, &unresolved) {
+		t.Fatalf("expected UnresolvedError, got %v", err)
+	}
+}
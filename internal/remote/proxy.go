@@ -0,0 +1,193 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyConfig describes an HTTP CONNECT or SOCKS5 proxy used to reach the
+// first SSH host.
+type ProxyConfig struct {
+	// URL of the proxy, with an http, https or socks5 scheme.
+	URL string
+	// Username and Password override credentials embedded in the URL.
+	Username string
+	Password string
+	// NoProxy lists hosts, domain suffixes and CIDR ranges that are dialed directly.
+	NoProxy []string
+}
+
+// ProxyFromEnvironment returns the proxy configured through the HTTPS_PROXY
+// or ALL_PROXY environment variables, or nil when neither is set.
+func ProxyFromEnvironment() *ProxyConfig {
+	proxyURL := getenvAny("HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy")
+	if proxyURL == "" {
+		return nil
+	}
+
+	var noProxy []string
+	for _, entry := range strings.Split(getenvAny("NO_PROXY", "no_proxy"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			noProxy = append(noProxy, entry)
+		}
+	}
+	return &ProxyConfig{URL: proxyURL, NoProxy: noProxy}
+}
+
+func getenvAny(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// bypass reports whether host matches an entry of NoProxy.
+func (p *ProxyConfig) bypass(host string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		switch {
+		case entry == "*":
+			return true
+		case ip != nil && strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+		case strings.EqualFold(host, strings.TrimPrefix(entry, ".")):
+			return true
+		case strings.HasSuffix(strings.ToLower(host), "."+strings.TrimPrefix(entry, ".")):
+			return true
+		}
+	}
+	return false
+}
+
+// dial opens a connection to addr through the proxy.
+func (p *ProxyConfig) dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	username, password := p.Username, p.Password
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if username != "" {
+			auth = &proxy.Auth{User: username, Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", hostPort(u, "1080"), auth, dialer)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect through socks5 proxy %s: %w", u.Host, err)
+		}
+		return conn, nil
+	case "http", "https":
+		return dialConnect(ctx, dialer, u, username, password, addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT request.
+func dialConnect(ctx context.Context, dialer *net.Dialer, u *url.URL, username, password, addr string) (net.Conn, error) {
+	defaultPort := "80"
+	if u.Scheme == "https" {
+		defaultPort = "443"
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(u, defaultPort))
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else if dialer.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(dialer.Timeout))
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", u.Host, addr, res.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	// The SSH server may already have sent its banner, so keep reading
+	// through the buffered reader.
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
@@ -0,0 +1,145 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	osexec "os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expandProxyCommand substitutes %h, %p, %r and %% in a ProxyCommand.
+func expandProxyCommand(command string, c *Config) string {
+	port := c.Port
+	if port == "" {
+		port = "22"
+	}
+	return strings.NewReplacer(
+		"%%", "%",
+		"%h", c.Host,
+		"%p", port,
+		"%r", c.User,
+	).Replace(command)
+}
+
+// dialCommand starts command and returns a connection speaking over its
+// stdin and stdout, like OpenSSH's ProxyCommand.
+func dialCommand(ctx context.Context, command string, c *Config) (net.Conn, error) {
+	command = expandProxyCommand(command, c)
+
+	cmd := shellCommand(context.Background(), command)
+
+	conn := &commandConn{cmd: cmd}
+	cmd.Stderr = &conn.stderr
+	// Helpers may leave children holding stderr open after being killed.
+	cmd.WaitDelay = time.Second
+
+	// Pipes are created here rather than with StdinPipe and StdoutPipe,
+	// which hide the files and with them their deadlines.
+	stdin, cmdStdin, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmdStdout, stdout, err := os.Pipe()
+	if err != nil {
+		cmdStdin.Close()
+		stdin.Close()
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout = stdin, stdout
+	conn.stdin, conn.stdout = cmdStdin, cmdStdout
+
+	err = cmd.Start()
+	stdin.Close()
+	stdout.Close()
+	if err != nil {
+		cmdStdin.Close()
+		cmdStdout.Close()
+		return nil, fmt.Errorf("unable to start proxy command %q: %w", command, err)
+	}
+
+	// Tie the helper to the connection attempt until the handshake is done.
+	conn.done = make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-conn.done:
+		}
+	}()
+	return conn, nil
+}
+
+// shellCommand runs command through the local shell.
+func shellCommand(ctx context.Context, command string) *osexec.Cmd {
+	if runtime.GOOS == "windows" {
+		return osexec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return osexec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// commandConn is a net.Conn over the stdio of a proxy command.
+type commandConn struct {
+	cmd    *osexec.Cmd
+	stdin  *os.File
+	stdout *os.File
+	stderr bytes.Buffer
+	done   chan struct{}
+
+	closeOnce   sync.Once
+	releaseOnce sync.Once
+}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		if c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+		_ = c.cmd.Wait()
+		_ = c.stdout.Close()
+	})
+	c.release()
+	return nil
+}
+
+// release detaches the command from the dialing context.
+func (c *commandConn) release() {
+	c.releaseOnce.Do(func() { close(c.done) })
+}
+
+// output returns what the command wrote to stderr, once it has exited.
+func (c *commandConn) output() string {
+	return strings.TrimSpace(c.stderr.String())
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr{} }
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	if err := c.stdout.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.stdin.SetWriteDeadline(t)
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return c.stdout.SetReadDeadline(t) }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return c.stdin.SetWriteDeadline(t) }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "proxycommand" }
+func (commandAddr) String() string  { return "proxycommand" }
@@ -0,0 +1,213 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	osexec "os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startConnectProxy serves HTTP CONNECT requests, requiring basic auth
+// credentials when user is set.
+func startConnectProxy(t *testing.T, user, password string) (string, *int32) {
+	t.Helper()
+	var tunnels int32
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				if u, p, ok := proxyBasicAuth(req); user != "" && (!ok || u != user || p != password) {
+					_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+				atomic.AddInt32(&tunnels, 1)
+				_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go func() { _, _ = io.Copy(target, conn) }()
+				_, _ = io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+	return "http://" + listener.Addr().String(), &tunnels
+}
+
+func proxyBasicAuth(req *http.Request) (string, string, bool) {
+	req.Header.Set("Authorization", req.Header.Get("Proxy-Authorization"))
+	return req.BasicAuth()
+}
+
+// startSOCKS5Proxy serves unauthenticated SOCKS5 CONNECT requests.
+func startSOCKS5Proxy(t *testing.T) (string, *int32) {
+	t.Helper()
+	var tunnels int32
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				greeting := make([]byte, 2)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte{5, 0})
+
+				header := make([]byte, 4)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				var host string
+				switch header[3] {
+				case 1:
+					ip := make([]byte, 4)
+					_, _ = io.ReadFull(conn, ip)
+					host = net.IP(ip).String()
+				case 3:
+					size := make([]byte, 1)
+					_, _ = io.ReadFull(conn, size)
+					name := make([]byte, size[0])
+					_, _ = io.ReadFull(conn, name)
+					host = string(name)
+				default:
+					return
+				}
+				port := make([]byte, 2)
+				_, _ = io.ReadFull(conn, port)
+
+				target, err := net.Dial("tcp", net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port))))
+				if err != nil {
+					_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				atomic.AddInt32(&tunnels, 1)
+				_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go func() { _, _ = io.Copy(target, conn) }()
+				_, _ = io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+	return "socks5://" + listener.Addr().String(), &tunnels
+}
+
+func TestConfig_RunThroughProxy(t *testing.T) {
+	server := newTestServer(t)
+	httpProxy, httpTunnels := startConnectProxy(t, "", "")
+	authProxy, authTunnels := startConnectProxy(t, "alice", "hunter2")
+	socksProxy, socksTunnels := startSOCKS5Proxy(t)
+
+	tests := []struct {
+		name        string
+		proxy       *ProxyConfig
+		tunnels     *int32
+		wantTunnels int32
+		wantErr     bool
+	}{
+		{name: "http connect", proxy: &ProxyConfig{URL: httpProxy}, tunnels: httpTunnels, wantTunnels: 1},
+		{name: "http connect with credentials", proxy: &ProxyConfig{URL: authProxy, Username: "alice", Password: "hunter2"}, tunnels: authTunnels, wantTunnels: 1},
+		{name: "http connect rejected credentials", proxy: &ProxyConfig{URL: authProxy, Username: "alice", Password: "wrong"}, tunnels: authTunnels, wantTunnels: 1, wantErr: true},
+		{name: "socks5", proxy: &ProxyConfig{URL: socksProxy}, tunnels: socksTunnels, wantTunnels: 1},
+		{name: "no_proxy", proxy: &ProxyConfig{URL: httpProxy, NoProxy: []string{"127.0.0.0/8"}}, tunnels: httpTunnels, wantTunnels: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := server.config("deploy")
+			config.Proxy = tt.proxy
+
+			_, _, err := config.Run(context.Background(), "hostname", 10*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(tt.tunnels); got != tt.wantTunnels {
+				t.Errorf("proxy tunnels = %d, want %d", got, tt.wantTunnels)
+			}
+		})
+	}
+}
+
+func TestProxyConfig_bypass(t *testing.T) {
+	p := &ProxyConfig{NoProxy: []string{".internal.example.com", "db.example.com", "10.0.0.0/8"}}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "web.internal.example.com", want: true},
+		{host: "internal.example.com", want: true},
+		{host: "db.example.com", want: true},
+		{host: "example.com", want: false},
+		{host: "10.1.2.3", want: true},
+		{host: "192.168.1.1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := p.bypass(tt.host); got != tt.want {
+				t.Errorf("ProxyConfig.bypass(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_RunThroughProxyCommand(t *testing.T) {
+	if _, err := osexec.LookPath("bash"); err != nil {
+		t.Skip("bash is required for the proxy command")
+	}
+	server := newTestServer(t)
+
+	config := server.config("deploy")
+	config.ProxyCommand = `exec bash -c 'exec 3<>/dev/tcp/%h/%p; cat <&3 & cat >&3'`
+
+	stdout, _, err := config.Run(context.Background(), "whoami", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Config.Run() error = %v", err)
+	}
+	if stdout != "whoami\n" {
+		t.Errorf("Config.Run() stdout = %q, want %q", stdout, "whoami\n")
+	}
+}
+
+func TestExpandProxyCommand(t *testing.T) {
+	config := &Config{Host: "db.internal", User: "deploy"}
+	got := expandProxyCommand("cloudflared access ssh --hostname %h:%p --id %r 100%%", config)
+	want := "cloudflared access ssh --hostname db.internal:22 --id deploy 100%"
+	if got != want {
+		t.Errorf("expandProxyCommand() = %q, want %q", got, want)
+	}
+}
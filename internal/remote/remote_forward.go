@@ -0,0 +1,169 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RemoteForward exposes a local service on the host, the same way as ssh -R.
+// Connections accepted by the host on RemoteAddress are forwarded to
+// LocalAddress, or served from Directory over HTTP.
+type RemoteForward struct {
+	// RemoteAddress is the address the host listens on. Port 0 lets the
+	// host pick a free port. Defaults to 127.0.0.1:0.
+	RemoteAddress string
+	// LocalAddress is dialed from this machine for every connection.
+	LocalAddress string
+	// Directory is served over HTTP instead of forwarding to LocalAddress.
+	Directory string
+	// Env names an environment variable set for each command to the address
+	// the host listens on, or to its URL when Directory is served.
+	Env string
+}
+
+// Forwarding holds the listeners opened on the host by Forward. They stay
+// open until Close is called.
+type Forwarding struct {
+	// Environment maps the Env of each forward to the address it listens on.
+	Environment map[string]string
+
+	closers []io.Closer
+	release func()
+}
+
+// Close stops listening on the host and releases the connection.
+func (f *Forwarding) Close() error {
+	var err error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if cerr := f.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if f.release != nil {
+		f.release()
+	}
+	return err
+}
+
+// Forward opens a listener on the host for each forward.
+func (c *Config) Forward(ctx context.Context, forwards []RemoteForward) (*Forwarding, error) {
+	client, release, err := c.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Forwarding{Environment: map[string]string{}, release: release}
+	for _, forward := range forwards {
+		if err := f.open(client, forward, c.Timeout); err != nil {
+			err = keepaliveError(client, err)
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *Forwarding) open(client *ssh.Client, forward RemoteForward, timeout time.Duration) error {
+	if forward.Directory != "" {
+		info, err := os.Stat(forward.Directory)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", forward.Directory)
+		}
+	}
+
+	addr := forward.RemoteAddress
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := client.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s on the host: %w", addr, err)
+	}
+
+	value := listener.Addr().String()
+	if forward.Directory != "" {
+		server := &http.Server{
+			Handler:           fileServer{root: http.Dir(forward.Directory)},
+			ReadHeaderTimeout: time.Minute,
+		}
+		go func() { _ = server.Serve(listener) }()
+		f.closers = append(f.closers, server)
+		value = "http://" + value
+	} else {
+		go forwardConnections(listener, forward.LocalAddress, timeout)
+		f.closers = append(f.closers, listener)
+	}
+
+	if forward.Env != "" {
+		f.Environment[forward.Env] = value
+	}
+	return nil
+}
+
+// fileServer serves the files under root. Unlike http.FileServer it does
+// not list directories, and refuses paths with a component starting with a
+// dot, e.g. .git or .env, as any user on the host can reach the listener.
+type fileServer struct {
+	root http.Dir
+}
+
+func (s fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	file, err := s.root.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// forwardConnections dials target for every connection accepted by listener
+// until it is closed.
+func forwardConnections(listener net.Listener, target string, timeout time.Duration) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			local, err := net.DialTimeout("tcp", target, timeout)
+			if err != nil {
+				return
+			}
+			defer local.Close()
+			go func() {
+				_, _ = io.Copy(local, conn)
+				if tcp, ok := local.(*net.TCPConn); ok {
+					_ = tcp.CloseWrite()
+				}
+			}()
+			_, _ = io.Copy(conn, local)
+		}()
+	}
+}
@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_Forward(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "artifact.txt"), []byte("payload"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { echo.Close() })
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				_, _ = io.WriteString(conn, "echo: "+line)
+			}()
+		}
+	}()
+
+	fetch := func(t *testing.T, url string) string {
+		resp, err := http.Get(url + "/artifact.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	dial := func(t *testing.T, addr string) string {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "ping\n")
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		return line
+	}
+
+	tests := []struct {
+		name    string
+		forward RemoteForward
+		check   func(t *testing.T, value string) string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "directory",
+			forward: RemoteForward{Directory: dir, Env: "ARTIFACTS"},
+			check:   fetch,
+			want:    "payload",
+		},
+		{
+			name:    "local address",
+			forward: RemoteForward{LocalAddress: echo.Addr().String(), Env: "ECHO"},
+			check:   dial,
+			want:    "echo: ping\n",
+		},
+		{
+			name:    "missing directory",
+			forward: RemoteForward{Directory: filepath.Join(dir, "missing"), Env: "ARTIFACTS"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestServer(t).config("deploy")
+
+			f, err := config.Forward(context.Background(), []RemoteForward{tt.forward})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config.Forward() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer f.Close()
+
+			value, ok := f.Environment[tt.forward.Env]
+			if !ok {
+				t.Fatalf("Config.Forward() environment = %v, missing %s", f.Environment, tt.forward.Env)
+			}
+			if got := tt.check(t, value); got != tt.want {
+				t.Errorf("forwarded response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"artifact.txt":     "payload",
+		".env":             "TOKEN=s3cr3t",
+		".git/config":      "[core]",
+		"nested/file.txt":  "nested",
+		"nested/.htaccess": "deny",
+	} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{path: "/artifact.txt", wantStatus: http.StatusOK, wantBody: "payload"},
+		{path: "/nested/file.txt", wantStatus: http.StatusOK, wantBody: "nested"},
+		{path: "/", wantStatus: http.StatusNotFound},
+		{path: "/nested/", wantStatus: http.StatusNotFound},
+		{path: "/.env", wantStatus: http.StatusNotFound},
+		{path: "/.git/config", wantStatus: http.StatusNotFound},
+		{path: "/nested/.htaccess", wantStatus: http.StatusNotFound},
+		{path: "/nested/../.env", wantStatus: http.StatusNotFound},
+		{path: "/missing", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			fileServer{root: http.Dir(dir)}.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
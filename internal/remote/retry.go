@@ -0,0 +1,174 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies why an operation on the host failed, to decide
+// whether it is retried.
+type ErrorKind string
+
+const (
+	// ErrorAuth means the host rejected every authentication method.
+	ErrorAuth ErrorKind = "auth"
+	// ErrorHostKey means the host key could not be verified.
+	ErrorHostKey ErrorKind = "host_key"
+	// ErrorDial means the host, a jump host or the proxy could not be
+	// reached, or dropped the connection during the handshake.
+	ErrorDial ErrorKind = "dial"
+	// ErrorSession means a session could not be opened on an established
+	// connection.
+	ErrorSession ErrorKind = "session"
+	// ErrorDisconnect means the connection was lost while a command ran, so
+	// the command may have run in part.
+	ErrorDisconnect ErrorKind = "disconnect"
+	// ErrorCommand means the command ran but failed, because of its exit
+	// status or its output.
+	ErrorCommand ErrorKind = "command"
+	// ErrorTimeout means the command did not finish in time.
+	ErrorTimeout ErrorKind = "timeout"
+)
+
+// ErrorKinds lists every kind of error, in the order they can occur.
+var ErrorKinds = []ErrorKind{ErrorDial, ErrorHostKey, ErrorAuth, ErrorSession, ErrorDisconnect, ErrorTimeout, ErrorCommand}
+
+// Error is an error of a known kind.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the kind of err, or "" when it is not a failure of the
+// connection or the command, e.g. invalid settings.
+func Classify(err error) ErrorKind {
+	var hostKeyErr *HostKeyError
+	var cmdErr *CommandError
+	var keepaliveErr *KeepaliveError
+	var kindErr *Error
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &hostKeyErr):
+		return ErrorHostKey
+	case errors.As(err, &cmdErr), isExitError(err):
+		return ErrorCommand
+	case errors.As(err, &kindErr):
+		return kindErr.Kind
+	case errors.As(err, &keepaliveErr):
+		return ErrorDisconnect
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &netErr):
+		return ErrorDial
+	}
+	return ""
+}
+
+// handshakeError classifies an error returned by the SSH handshake.
+func handshakeError(err error) error {
+	err = algorithmError(err)
+	var algErr *AlgorithmError
+	if errors.As(err, &algErr) {
+		return err
+	}
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return &Error{Kind: ErrorAuth, Err: err}
+	}
+	return &Error{Kind: ErrorDial, Err: err}
+}
+
+// DefaultRetryOn lists the kinds of errors retried by default. They happen
+// before a command starts, so retrying them is safe for any command.
+// ErrorDisconnect is left out, since the command may have run in part.
+var DefaultRetryOn = []ErrorKind{ErrorDial, ErrorSession}
+
+const (
+	defaultMaxAttempts = 5
+	defaultMaxDelay    = time.Minute
+)
+
+// RetryPolicy decides whether and when a failed operation is attempted
+// again.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times an operation is attempted.
+	// Defaults to 5.
+	MaxAttempts int
+	// Delay is the delay before the first retry. It doubles after every
+	// attempt, up to MaxDelay, and is jittered by up to half.
+	Delay time.Duration
+	// MaxDelay caps the delay between attempts. Defaults to a minute.
+	MaxDelay time.Duration
+	// RetryOn lists the kinds of errors retried. Defaults to DefaultRetryOn.
+	RetryOn []ErrorKind
+}
+
+// retries reports whether an operation that failed with err on the given
+// attempt, counting from 1, is attempted again.
+func (p RetryPolicy) retries(err error, attempt int) bool {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if attempt >= maxAttempts {
+		return false
+	}
+
+	retryOn := p.RetryOn
+	if retryOn == nil {
+		retryOn = DefaultRetryOn
+	}
+	kind := Classify(err)
+	for _, k := range retryOn {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the attempt following the given one.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	maxDelay := p.MaxDelay
+	if maxDelay == 0 {
+		maxDelay = defaultMaxDelay
+	}
+
+	delay := p.Delay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// wait sleeps before the attempt following the given one, returning early
+// with the context error when it expires.
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "host key", err: &HostKeyError{Host: "example.com"}, want: ErrorHostKey},
+		{name: "auth", err: handshakeError(errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")), want: ErrorAuth},
+		{name: "handshake dropped", err: handshakeError(io.EOF), want: ErrorDial},
+		{name: "wrapped dial", err: fmt.Errorf("unable to connect to jump host: %w", &Error{Kind: ErrorDial, Err: io.EOF}), want: ErrorDial},
+		{name: "connection lost", err: &KeepaliveError{Host: "example.com", Missed: 3}, want: ErrorDisconnect},
+		{name: "connection lost while running", err: &Error{Kind: ErrorDisconnect, Err: io.EOF}, want: ErrorDisconnect},
+		{name: "session on a lost connection", err: &Error{Kind: ErrorSession, Err: fmt.Errorf("%w: EOF", &KeepaliveError{Host: "example.com", Missed: 3})}, want: ErrorSession},
+		{name: "command", err: &CommandError{Reason: "exited with status 1"}, want: ErrorCommand},
+		{name: "deadline", err: context.DeadlineExceeded, want: ErrorTimeout},
+		{name: "algorithms", err: handshakeError(errors.New("ssh: no common algorithm for client to server cipher; client offered: [aes128-ctr], server offered: [aes256-ctr]")), want: ""},
+		{name: "unclassified", err: errors.New("unable to read private key"), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{Delay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := p.backoff(tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestProvisioner_ExecuteRetry(t *testing.T) {
+	// The listener drops every connection before the handshake.
+	var accepted int32
+	dropping, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dropping.Close() })
+	go func() {
+		for {
+			conn, err := dropping.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name         string
+		config       func(s *testServer) *Config
+		command      string
+		retry        RetryPolicy
+		wantKind     ErrorKind
+		wantAttempts func(s *testServer) int
+		want         int
+	}{
+		{
+			name: "dial retried",
+			config: func(*testServer) *Config {
+				host, port, _ := net.SplitHostPort(dropping.Addr().String())
+				return &Config{Host: host, Port: port, User: "alice", Password: "secret"}
+			},
+			command:      "true",
+			retry:        RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond},
+			wantKind:     ErrorDial,
+			wantAttempts: func(*testServer) int { return int(atomic.SwapInt32(&accepted, 0)) },
+			want:         3,
+		},
+		{
+			name: "auth not retried",
+			config: func(s *testServer) *Config {
+				c := s.config("alice")
+				c.Password = "wrong"
+				return c
+			},
+			command:  "true",
+			retry:    RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond},
+			wantKind: ErrorAuth,
+		},
+		{
+			name:         "command not retried",
+			config:       func(s *testServer) *Config { return s.config("alice") },
+			command:      "exit 1",
+			retry:        RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond},
+			wantKind:     ErrorCommand,
+			wantAttempts: func(s *testServer) int { return len(s.commands) },
+			want:         1,
+		},
+		{
+			name:         "command retried on request",
+			config:       func(s *testServer) *Config { return s.config("alice") },
+			command:      "exit 1",
+			retry:        RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond, RetryOn: []ErrorKind{ErrorCommand}},
+			wantKind:     ErrorCommand,
+			wantAttempts: func(s *testServer) int { return len(s.commands) },
+			want:         3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			p := NewProvisioner(tt.config(server), 10*time.Second, 0).WithRetry(tt.retry)
+
+			_, err := p.Execute([]Command{{Command: tt.command}}, context.Background())
+			if got := Classify(err); got != tt.wantKind {
+				t.Fatalf("Execute() error = %v, classified %q, want %q", err, got, tt.wantKind)
+			}
+			if tt.wantAttempts == nil {
+				return
+			}
+			server.mu.Lock()
+			got := tt.wantAttempts(server)
+			server.mu.Unlock()
+			if got != tt.want {
+				t.Errorf("attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,17 +5,15 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/appkins/terraform-provider-ssh/internal/log"
-	"github.com/loafoe/easyssh-proxy/v2"
 )
 
-func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeConfig, createFiles []File) error {
+func copyFiles(ctx context.Context, retry RetryPolicy, ssh *Config, createFiles []File) error {
 	for _, f := range createFiles {
 		copyFile := func(f File) error {
-			if !f.Source.IsUnknown() {
-				src, srcErr := os.Open(f.Source.String())
+			if !f.Source.IsNull() {
+				src, srcErr := os.Open(f.Source.ValueString())
 				if srcErr != nil {
 					log.Debug(ctx, "Failed to open source file %s: %v\n", f.Source, srcErr)
 					return srcErr
@@ -26,36 +24,40 @@ func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeC
 					_ = src.Close()
 					return statErr
 				}
-				_ = ssh.WriteFile(src, srcStat.Size(), f.Destination.String())
-				log.Debug(ctx, "Copied %s to remote file %s:%s: %d bytes\n", f.Source, ssh.Server, f.Destination, srcStat.Size())
+				if err := ssh.WriteFile(ctx, src, srcStat.Size(), f.Destination.ValueString()); err != nil {
+					log.Debug(ctx, "Failed to copy %s to remote file %s:%s: %v\n", f.Source, ssh.Host, f.Destination, err)
+					_ = src.Close()
+					return err
+				}
+				log.Debug(ctx, "Copied %s to remote file %s:%s: %d bytes\n", f.Source, ssh.Host, f.Destination, srcStat.Size())
 				_ = src.Close()
 			} else {
-				buffer := bytes.NewBufferString(f.Content.String())
-				if err := ssh.WriteFile(buffer, int64(buffer.Len()), f.Destination.String()); err != nil {
-					log.Debug(ctx, "Failed to copy content to remote file %s:%s:%s: %v\n", ssh.Server, ssh.Port, f.Destination, err)
+				buffer := bytes.NewBufferString(f.Content.ValueString())
+				if err := ssh.WriteFile(ctx, buffer, int64(buffer.Len()), f.Destination.ValueString()); err != nil {
+					log.Debug(ctx, "Failed to copy content to remote file %s:%s:%s: %v\n", ssh.Host, ssh.Port, f.Destination, err)
 					return err
 				}
-				log.Debug(ctx, "Created remote file %s:%s:%s: %d bytes\n", ssh.Server, ssh.Port, f.Destination, len(f.Content.String()))
+				log.Debug(ctx, "Created remote file %s:%s:%s: %d bytes\n", ssh.Host, ssh.Port, f.Destination, len(f.Content.ValueString()))
 			}
 			// Permissions change
-			if !f.Permissions.IsUnknown() {
-				outStr, errStr, _, err := ssh.Run(fmt.Sprintf("chmod %s \"%s\"", f.Permissions, f.Destination))
+			if !f.Permissions.IsNull() {
+				outStr, errStr, err := ssh.Run(ctx, fmt.Sprintf("chmod %s \"%s\"", f.Permissions.ValueString(), f.Destination.ValueString()), 0)
 				log.Debug(ctx, "Permissions file %s:%s: %v %v\n", f.Destination, f.Permissions, outStr, errStr)
 				if err != nil {
 					return err
 				}
 			}
 			// Owner
-			if !f.Owner.IsUnknown() {
-				outStr, errStr, _, err := ssh.Run(fmt.Sprintf("chown %s \"%s\"", f.Owner, f.Destination))
+			if !f.Owner.IsNull() {
+				outStr, errStr, err := ssh.Run(ctx, fmt.Sprintf("chown %s \"%s\"", f.Owner.ValueString(), f.Destination.ValueString()), 0)
 				log.Debug(ctx, "Owner file %s:%s: %v %v\n", f.Destination, f.Owner, outStr, errStr)
 				if err != nil {
 					return err
 				}
 			}
 			// Group
-			if !f.Group.IsUnknown() {
-				outStr, errStr, _, err := ssh.Run(fmt.Sprintf("chgrp %s \"%s\"", f.Group, f.Destination))
+			if !f.Group.IsNull() {
+				outStr, errStr, err := ssh.Run(ctx, fmt.Sprintf("chgrp %s \"%s\"", f.Group.ValueString(), f.Destination.ValueString()), 0)
 				log.Debug(ctx, "Group file %s:%s: %v %v\n", f.Destination, f.Group, outStr, errStr)
 				if err != nil {
 					return err
@@ -63,16 +65,16 @@ func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeC
 			}
 			return nil
 		}
-		for {
+		for attempt := 1; ; attempt++ {
 			err := copyFile(f)
 			if err == nil {
 				break
 			}
-			select {
-			case <-time.After(retryDelay):
-			// Retry
-			case <-ctx.Done():
-				return fmt.Errorf("%s: %w", ctx.Err(), err)
+			if !retry.retries(err, attempt) {
+				return err
+			}
+			if waitErr := retry.wait(ctx, attempt); waitErr != nil {
+				return fmt.Errorf("%s: %w", waitErr, err)
 			}
 		}
 	}
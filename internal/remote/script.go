@@ -0,0 +1,77 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// DefaultInterpreter runs scripts of commands without an Interpreter.
+var DefaultInterpreter = []string{"/bin/sh"}
+
+// runScript uploads the script of cmd to a private temporary directory on
+// the host and runs it with the interpreter, so that it behaves the same
+// whatever the login shell of the user. The directory is removed afterwards.
+func (c *Config) runScript(ctx context.Context, cmd Command, timeout time.Duration) (*Result, error) {
+	interpreter := cmd.Interpreter
+	if len(interpreter) == 0 {
+		interpreter = DefaultInterpreter
+	}
+
+	dir, err := c.tempDir(ctx, timeout)
+	if err != nil {
+		return &Result{Command: commandLine(interpreter), ExitCode: -1, Start: time.Now()}, err
+	}
+	defer func() {
+		if _, stderr, err := c.Run(ctx, "rm -rf "+commandLine([]string{dir}), timeout); err != nil {
+			tflog.Warn(ctx, fmt.Sprintf("Unable to remove the script directory %s: %s %s", dir, err, stderr))
+		}
+	}()
+
+	script := path.Join(dir, "script")
+	if err := c.WriteFile(ctx, strings.NewReader(cmd.Script), int64(len(cmd.Script)), script); err != nil {
+		return &Result{Command: commandLine(interpreter), ExitCode: -1, Start: time.Now()}, fmt.Errorf("unable to upload the script: %w", err)
+	}
+
+	cmd.Command = commandLine(append(append([]string(nil), interpreter...), script))
+	cmd.Script = ""
+	return c.RunCommand(ctx, cmd, timeout)
+}
+
+// tempDir creates a directory only the user can access on the host.
+func (c *Config) tempDir(ctx context.Context, timeout time.Duration) (string, error) {
+	stdout, stderr, err := c.Run(ctx, "mktemp -d", timeout)
+	if isExitError(err) {
+		// The exit status belongs to mktemp, not to the script.
+		return "", fmt.Errorf("unable to create a temporary directory: %s", strings.TrimSpace(stderr))
+	}
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(stdout)
+	if !path.IsAbs(dir) || strings.Contains(dir, "\n") {
+		return "", fmt.Errorf("unable to create a temporary directory: mktemp printed %q", stdout)
+	}
+	return dir, nil
+}
+
+var plainArgument = regexp.MustCompile(`^[A-Za-z0-9_./=:,+@%-]+$`)
+
+// commandLine joins args into a command line, quoting those that need it.
+// Arguments made of plain characters are left bare, as every shell from sh
+// to fish and csh parses them the same way.
+func commandLine(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !plainArgument.MatchString(arg) {
+			arg = shellQuote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProvisioner_ExecuteScript(t *testing.T) {
+	const script = "set -e\necho 'hello world'\n"
+
+	tests := []struct {
+		name        string
+		interpreter []string
+		want        string
+	}{
+		{
+			name: "default interpreter",
+			want: "/bin/sh /tmp/tmp.1/script",
+		},
+		{
+			name:        "strict mode",
+			interpreter: []string{"/bin/bash", "-euo", "pipefail"},
+			want:        "/bin/bash -euo pipefail /tmp/tmp.1/script",
+		},
+		{
+			name:        "quoted arguments",
+			interpreter: []string{"env", "GREETING=hello world", "python3"},
+			want:        "env 'GREETING=hello world' python3 /tmp/tmp.1/script",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			p := NewProvisioner(server.config("deploy"), 10*time.Second, 0)
+
+			results, err := p.Execute([]Command{{Script: script, Interpreter: tt.interpreter}}, context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := results[0].Command; got != tt.want {
+				t.Errorf("Command = %q, want %q", got, tt.want)
+			}
+			if got, want := results[0].Stdout, tt.want+"\n"+script; got != want {
+				t.Errorf("Stdout = %q, want %q", got, want)
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			wantCommands := []string{"mktemp -d", `scp -t "/tmp/tmp.1/script"`, tt.want, "rm -rf /tmp/tmp.1"}
+			if len(server.commands) != len(wantCommands) {
+				t.Fatalf("commands = %q, want %q", server.commands, wantCommands)
+			}
+			for i, want := range wantCommands {
+				if server.commands[i] != want {
+					t.Errorf("commands[%d] = %q, want %q", i, server.commands[i], want)
+				}
+			}
+			if len(server.files) != 0 {
+				t.Errorf("files = %q, want them removed", server.files)
+			}
+		})
+	}
+}
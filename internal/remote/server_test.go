@@ -0,0 +1,491 @@
+package remote
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testServer is a minimal in-process SSH server. It accepts the password
+// "secret", echoes exec commands back on stdout and forwards direct-tcpip
+// channels so it can serve as a jump host. Sessions that request agent
+// forwarding also print the comments of the forwarded keys, and tcpip-forward
+// requests listen on the loopback interface. The commands "exit N" and
+// "kill SIG" exit with status N and are terminated by SIG, "sleep D" waits
+// for the duration D before exiting, and "mktemp -d", "scp -t" and "rm -rf"
+// manage files kept in memory.
+type testServer struct {
+	t        *testing.T
+	listener net.Listener
+	server   *ssh.ServerConfig
+	hostKey  ssh.Signer
+
+	mu       sync.Mutex
+	commands []string
+	conns    int
+
+	// ignoreRequests leaves global requests such as keepalives unanswered.
+	ignoreRequests bool
+	// acceptEnv lists the variables accepted in env requests, which are
+	// printed after the command.
+	acceptEnv []string
+	// files holds the files uploaded with scp, whose content is printed
+	// after commands that name them.
+	files    map[string]string
+	tempDirs int
+	// maxSessions refuses sessions beyond this many open ones on a
+	// connection, like OpenSSH's MaxSessions.
+	maxSessions int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{t: t, listener: listener, server: config, hostKey: hostKey}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *testServer) config(user string) *Config {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return &Config{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: "secret",
+		HostKeys: HostKeyConfig{Fingerprints: []string{ssh.FingerprintSHA256(s.hostKey.PublicKey())}},
+	}
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	s.mu.Lock()
+	config := *s.server
+	s.mu.Unlock()
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, &config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sshConn.Close()
+
+	s.mu.Lock()
+	s.conns++
+	s.mu.Unlock()
+
+	s.mu.Lock()
+	ignore := s.ignoreRequests
+	s.mu.Unlock()
+	if ignore {
+		go func() {
+			for range reqs {
+			}
+		}()
+	} else {
+		go s.handleRequests(sshConn, reqs)
+	}
+	var sessions int32
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			s.mu.Lock()
+			maxSessions := s.maxSessions
+			s.mu.Unlock()
+			if maxSessions > 0 && atomic.LoadInt32(&sessions) >= int32(maxSessions) {
+				_ = newChannel.Reject(ssh.ResourceShortage, "no more sessions")
+				continue
+			}
+			atomic.AddInt32(&sessions, 1)
+			go func(newChannel ssh.NewChannel) {
+				defer atomic.AddInt32(&sessions, -1)
+				s.handleSession(sshConn, newChannel)
+			}(newChannel)
+		case "direct-tcpip":
+			go s.handleDirectTCPIP(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *testServer) handleSession(conn ssh.Conn, newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	forwardAgent := false
+	env := map[string]string{}
+	for req := range reqs {
+		if req.Type == "env" {
+			var payload struct{ Name, Value string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			s.mu.Lock()
+			accepted := false
+			for _, name := range s.acceptEnv {
+				accepted = accepted || name == payload.Name
+			}
+			s.mu.Unlock()
+			if accepted {
+				env[payload.Name] = payload.Value
+			}
+			_ = req.Reply(accepted, nil)
+			continue
+		}
+		if req.Type == "auth-agent-req@openssh.com" {
+			forwardAgent = true
+			if req.WantReply {
+				_ = req.Reply(true, nil)
+			}
+			continue
+		}
+		if req.Type != "exec" {
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+			continue
+		}
+		var payload struct{ Command string }
+		_ = ssh.Unmarshal(req.Payload, &payload)
+		_ = req.Reply(true, nil)
+
+		s.mu.Lock()
+		s.commands = append(s.commands, payload.Command)
+		s.mu.Unlock()
+
+		// Run the command with the variables refused in env requests.
+		command, exported := unwrapEnvironment(payload.Command, channel)
+		for name, value := range exported {
+			env[name] = value
+		}
+		if s.handleFiles(channel, command) {
+			_, _ = channel.SendRequest("exit-status", false, make([]byte, 4))
+			return
+		}
+
+		_, _ = io.WriteString(channel, payload.Command+"\n")
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(channel, "%s=%s\n", name, env[name])
+		}
+		if forwardAgent {
+			s.listAgentKeys(conn, channel)
+		}
+		s.mu.Lock()
+		for _, arg := range strings.Fields(command) {
+			if content, ok := s.files[arg]; ok {
+				_, _ = io.WriteString(channel, content)
+			}
+		}
+		s.mu.Unlock()
+
+		var code uint32
+		var signal string
+		var sleep string
+		if _, err := fmt.Sscanf(command, "sleep %s", &sleep); err == nil {
+			d, _ := time.ParseDuration(sleep)
+			time.Sleep(d)
+		}
+		if _, err := fmt.Sscanf(command, "kill %s", &signal); err == nil {
+			_, _ = channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+				Signal     string
+				CoreDumped bool
+				Message    string
+				Lang       string
+			}{Signal: signal}))
+			return
+		}
+		if _, err := fmt.Sscanf(command, "exit %d", &code); err == nil {
+			fmt.Fprintf(channel.Stderr(), "exiting with %d\n", code)
+		}
+		status := make([]byte, 4)
+		binary.BigEndian.PutUint32(status, code)
+		_, _ = channel.SendRequest("exit-status", false, status)
+		return
+	}
+}
+
+// unwrapEnvironment undoes exportEnvironment, returning the command and the
+// variables it sets. Sensitive variables are read from stdin.
+func unwrapEnvironment(command string, stdin io.Reader) (string, map[string]string) {
+	env := map[string]string{}
+	args := shellWords(command)
+	if len(args) > 0 && args[0] == "env" {
+		args = args[1:]
+		for len(args) > 0 && strings.Contains(args[0], "=") {
+			name, value, _ := strings.Cut(args[0], "=")
+			env[name] = value
+			args = args[1:]
+		}
+	}
+	if len(args) != 3 || args[0] != "sh" || args[1] != "-c" {
+		return command, nil
+	}
+	command = args[2]
+
+	const eval = `eval "$(cat)" || exit 1; `
+	if strings.HasPrefix(command, eval) {
+		command = strings.TrimPrefix(command, eval)
+		exports, _ := io.ReadAll(stdin)
+		for _, line := range strings.Split(strings.TrimSpace(string(exports)), "\n") {
+			words := shellWords(line)
+			if len(words) == 2 && words[0] == "export" {
+				name, value, _ := strings.Cut(words[1], "=")
+				env[name] = value
+			}
+		}
+	}
+	return command, env
+}
+
+// shellWords splits s into words, handling the single quotes added by
+// shellQuote and nothing else.
+func shellWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+			inWord = true
+		case c == '\\' && !quoted && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case c == ' ' && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			inWord = false
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// handleFiles runs the file commands, reporting whether command was one.
+func (s *testServer) handleFiles(channel ssh.Channel, command string) bool {
+	var upload []byte
+	if strings.HasPrefix(command, "scp -t ") {
+		upload, _ = io.ReadAll(channel)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case command == "mktemp -d":
+		s.tempDirs++
+		fmt.Fprintf(channel, "/tmp/tmp.%d\n", s.tempDirs)
+	case strings.HasPrefix(command, "scp -t "):
+		target := strings.Trim(strings.TrimPrefix(command, "scp -t "), `"`)
+		var mode string
+		var size int
+		header, content, _ := strings.Cut(string(upload), "\n")
+		if _, err := fmt.Sscanf(header, "C%s %d", &mode, &size); err != nil || size > len(content) {
+			s.t.Errorf("invalid scp upload %q", upload)
+			return true
+		}
+		if s.files == nil {
+			s.files = map[string]string{}
+		}
+		s.files[target] = content[:size]
+	case strings.HasPrefix(command, "rm -rf "):
+		dir := strings.TrimPrefix(command, "rm -rf ")
+		for name := range s.files {
+			if strings.HasPrefix(name, dir+"/") {
+				delete(s.files, name)
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *testServer) handleRequests(conn ssh.Conn, reqs <-chan *ssh.Request) {
+	listeners := map[string]net.Listener{}
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for req := range reqs {
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		switch req.Type {
+		case "tcpip-forward":
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(payload.Port)))
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			port := uint32(l.Addr().(*net.TCPAddr).Port)
+			listeners[fmt.Sprintf("%s:%d", payload.Addr, payload.Port)] = l
+			go s.forwardTCPIP(conn, l, payload.Addr, port)
+			_ = req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+		case "cancel-tcpip-forward":
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			key := fmt.Sprintf("%s:%d", payload.Addr, payload.Port)
+			if l, ok := listeners[key]; ok {
+				l.Close()
+				delete(listeners, key)
+			}
+			_ = req.Reply(true, nil)
+		default:
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// forwardTCPIP opens a forwarded-tcpip channel over conn for every
+// connection accepted by l.
+func (s *testServer) forwardTCPIP(conn ssh.Conn, l net.Listener, addr string, port uint32) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		origin := c.RemoteAddr().(*net.TCPAddr)
+		channel, reqs, err := conn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+			Addr       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}{addr, port, origin.IP.String(), uint32(origin.Port)}))
+		if err != nil {
+			c.Close()
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			_, _ = io.Copy(channel, c)
+			_ = channel.CloseWrite()
+		}()
+		go func() {
+			_, _ = io.Copy(c, channel)
+			c.Close()
+		}()
+	}
+}
+
+// listAgentKeys writes the comments of the keys held by the agent forwarded
+// over conn.
+func (s *testServer) listAgentKeys(conn ssh.Conn, w io.Writer) {
+	channel, reqs, err := conn.OpenChannel("auth-agent@openssh.com", nil)
+	if err != nil {
+		fmt.Fprintf(w, "agent: %s\n", err)
+		return
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(reqs)
+
+	keys, err := agent.NewClient(channel).List()
+	if err != nil {
+		fmt.Fprintf(w, "agent: %s\n", err)
+		return
+	}
+	for _, key := range keys {
+		fmt.Fprintf(w, "agent: %s\n", key.Comment)
+	}
+}
+
+func (s *testServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, fmt.Sprint(payload.Port)))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(target, channel)
+		target.Close()
+	}()
+	_, _ = io.Copy(channel, target)
+	channel.Close()
+}
+
+func (s *testServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
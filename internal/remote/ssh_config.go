@@ -0,0 +1,328 @@
+package remote
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSHConfigHost holds the settings an OpenSSH client config resolves for a host.
+type SSHConfigHost struct {
+	HostName            string
+	User                string
+	Port                string
+	IdentityFiles       []string
+	ProxyJump           string
+	UserKnownHostsFiles []string
+	ConnectTimeout      time.Duration
+}
+
+// Apply fills in the settings of c that are not already set. The host is
+// always replaced by HostName, since c.Host is the alias that was resolved.
+// ProxyJump is left to the caller, as the hops need credentials.
+func (h *SSHConfigHost) Apply(c *Config) {
+	if h.HostName != "" {
+		c.Host = h.HostName
+	}
+	if c.User == "" {
+		c.User = h.User
+	}
+	if c.Port == "" {
+		c.Port = h.Port
+	}
+	c.IdentityFiles = append(c.IdentityFiles, h.IdentityFiles...)
+	if c.HostKeys.IsEmpty() {
+		for _, f := range h.UserKnownHostsFiles {
+			if _, err := os.Stat(f); err == nil {
+				c.HostKeys.KnownHostsFiles = append(c.HostKeys.KnownHostsFiles, f)
+			}
+		}
+	}
+	if c.Timeout == 0 {
+		c.Timeout = h.ConnectTimeout
+	}
+}
+
+type sshConfigLine struct {
+	keyword string
+	args    []string
+	file    string
+	line    int
+}
+
+// ResolveSSHConfig reads the OpenSSH client config at file and resolves the
+// settings that apply to alias. Host and Match blocks are evaluated in order
+// and the first value obtained for each keyword wins, as in ssh_config(5).
+// Match exec criteria are not supported, so Match blocks using them are
+// skipped whether the criterion is negated or not.
+func ResolveSSHConfig(file string, alias string, localUser string) (*SSHConfigHost, error) {
+	lines, err := readSSHConfig(ExpandPath(file), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &SSHConfigHost{}
+	seen := map[string]bool{}
+	active := true
+
+	for _, l := range lines {
+		switch l.keyword {
+		case "host":
+			active = matchHostPatterns(l.args, alias)
+			continue
+		case "match":
+			hostname := alias
+			if resolved.HostName != "" {
+				hostname = resolved.HostName
+			}
+			user := localUser
+			if resolved.User != "" {
+				user = resolved.User
+			}
+			active, err = matchCriteria(l, alias, hostname, user, localUser)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !active || len(l.args) == 0 {
+			continue
+		}
+
+		// IdentityFile accumulates, everything else is set by the first
+		// matching occurrence. UserKnownHostsFile lists all its files there.
+		if seen[l.keyword] && l.keyword != "identityfile" {
+			continue
+		}
+		seen[l.keyword] = true
+
+		switch l.keyword {
+		case "hostname":
+			resolved.HostName = l.args[0]
+		case "user":
+			resolved.User = l.args[0]
+		case "port":
+			resolved.Port = l.args[0]
+		case "identityfile":
+			resolved.IdentityFiles = append(resolved.IdentityFiles, l.args[0])
+		case "proxyjump":
+			resolved.ProxyJump = l.args[0]
+		case "userknownhostsfile":
+			resolved.UserKnownHostsFiles = append(resolved.UserKnownHostsFiles, l.args...)
+		case "connecttimeout":
+			seconds, err := strconv.Atoi(l.args[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid ConnectTimeout %q", l.file, l.line, l.args[0])
+			}
+			resolved.ConnectTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if hostname := resolved.HostName; hostname != "" {
+		// %h in HostName refers to the alias itself.
+		resolved.HostName = ""
+		resolved.HostName = expandTokens(hostname, alias, resolved, localUser)
+	}
+	for i, f := range resolved.IdentityFiles {
+		resolved.IdentityFiles[i] = ExpandPath(expandTokens(f, alias, resolved, localUser))
+	}
+	for i, f := range resolved.UserKnownHostsFiles {
+		resolved.UserKnownHostsFiles[i] = ExpandPath(expandTokens(f, alias, resolved, localUser))
+	}
+	return resolved, nil
+}
+
+func readSSHConfig(file string, depth int) ([]sshConfigLine, error) {
+	if depth > 16 {
+		return nil, fmt.Errorf("%s: too many nested Include directives", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []sshConfigLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		keyword, args, err := splitSSHConfigLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+		if keyword == "" {
+			continue
+		}
+
+		if keyword != "include" {
+			lines = append(lines, sshConfigLine{keyword: keyword, args: args, file: file, line: n})
+			continue
+		}
+
+		for _, pattern := range args {
+			pattern = ExpandPath(pattern)
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(file), pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", file, n, err)
+			}
+			for _, match := range matches {
+				included, err := readSSHConfig(match, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, included...)
+			}
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// splitSSHConfigLine returns the lower-cased keyword and arguments of a line.
+func splitSSHConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	for rest != "" {
+		var arg string
+		if rest[0] == '"' {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				return "", nil, fmt.Errorf("unterminated quote")
+			}
+			arg, rest = rest[1:closing+1], rest[closing+2:]
+		} else if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			arg, rest = rest[:i], rest[i:]
+		} else {
+			arg, rest = rest, ""
+		}
+		if strings.HasPrefix(arg, "#") {
+			break
+		}
+		args = append(args, arg)
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return keyword, args, nil
+}
+
+// matchHostPatterns reports whether host matches a list of Host patterns,
+// where any negated match rejects the host.
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, list := range patterns {
+		for _, pattern := range strings.Split(list, ",") {
+			negate := strings.HasPrefix(pattern, "!")
+			if ok, _ := path.Match(strings.ToLower(strings.TrimPrefix(pattern, "!")), strings.ToLower(host)); ok {
+				if negate {
+					return false
+				}
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+func matchCriteria(l sshConfigLine, alias, hostname, user, localUser string) (bool, error) {
+	args := l.args
+	for i := 0; i < len(args); i++ {
+		criterion := strings.ToLower(args[i])
+		negate := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var ok bool
+		switch criterion {
+		case "all", "canonical", "final":
+			ok = true
+		case "host", "originalhost", "user", "localuser", "exec":
+			if i+1 >= len(args) {
+				return false, fmt.Errorf("%s:%d: Match %s requires an argument", l.file, l.line, criterion)
+			}
+			i++
+			if criterion == "exec" {
+				// The outcome of the command is unknown, so neither it nor
+				// its negation can be assumed.
+				return false, nil
+			}
+			value := map[string]string{"host": hostname, "originalhost": alias, "user": user, "localuser": localUser}[criterion]
+			ok = matchHostPatterns([]string{args[i]}, value)
+		default:
+			return false, fmt.Errorf("%s:%d: unsupported Match criterion %q", l.file, l.line, args[i])
+		}
+
+		if ok == negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// expandTokens substitutes the %h, %n, %p, %r, %u, %d and %% tokens.
+func expandTokens(value, alias string, h *SSHConfigHost, localUser string) string {
+	hostname := alias
+	if h.HostName != "" {
+		hostname = h.HostName
+	}
+	port := h.Port
+	if port == "" {
+		port = "22"
+	}
+	user := h.User
+	if user == "" {
+		user = localUser
+	}
+	home, _ := os.UserHomeDir()
+
+	return strings.NewReplacer(
+		"%%", "%",
+		"%h", hostname,
+		"%n", alias,
+		"%p", port,
+		"%r", user,
+		"%u", localUser,
+		"%d", home,
+	).Replace(value)
+}
+
+// ParseProxyJump splits a ProxyJump value such as "user@bastion:2222,gw"
+// into its hops. The value "none" yields no hops.
+func ParseProxyJump(value string) []*Config {
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil
+	}
+
+	var hops []*Config
+	for _, hop := range strings.Split(value, ",") {
+		hop = strings.TrimPrefix(strings.TrimSpace(hop), "ssh://")
+		c := &Config{}
+		if at := strings.LastIndex(hop, "@"); at >= 0 {
+			c.User, hop = hop[:at], hop[at+1:]
+		}
+		c.Host = hop
+		if host, port, err := net.SplitHostPort(hop); err == nil {
+			c.Host, c.Port = host, port
+		}
+		hops = append(hops, c)
+	}
+	return hops
+}
@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testSSHConfig = `
+# Bastion first so its settings win.
+Host bastion
+  HostName bastion.example.com
+  User jump
+
+Match !exec "test -f /etc/staging" originalhost web-*
+  User staging
+
+Host web-*  !web-legacy
+  HostName %h.internal.example.com
+  ProxyJump jump@bastion:2222,gw
+  IdentityFile ~/.ssh/web_ed25519
+
+Match originalhost db user admin
+  Port 2200
+
+Host db
+  HostName=10.0.0.5
+  UserKnownHostsFile /etc/ssh/known_hosts_db "/tmp/known hosts"
+
+Host *
+  User deploy
+  Port 22
+  IdentityFile ~/.ssh/id_ed25519
+  ConnectTimeout 15
+`
+
+func TestResolveSSHConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte(testSSHConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	home, _ := os.UserHomeDir()
+
+	tests := []struct {
+		name      string
+		alias     string
+		localUser string
+		want      *SSHConfigHost
+	}{
+		{
+			name:  "wildcard with token",
+			alias: "web-1",
+			want: &SSHConfigHost{
+				HostName:       "web-1.internal.example.com",
+				User:           "deploy",
+				Port:           "22",
+				IdentityFiles:  []string{filepath.Join(home, ".ssh/web_ed25519"), filepath.Join(home, ".ssh/id_ed25519")},
+				ProxyJump:      "jump@bastion:2222,gw",
+				ConnectTimeout: 15 * time.Second,
+			},
+		},
+		{
+			name:  "negated pattern",
+			alias: "web-legacy",
+			want: &SSHConfigHost{
+				User:           "deploy",
+				Port:           "22",
+				IdentityFiles:  []string{filepath.Join(home, ".ssh/id_ed25519")},
+				ConnectTimeout: 15 * time.Second,
+			},
+		},
+		{
+			name:      "match block",
+			alias:     "db",
+			localUser: "admin",
+			want: &SSHConfigHost{
+				HostName:            "10.0.0.5",
+				User:                "deploy",
+				Port:                "2200",
+				IdentityFiles:       []string{filepath.Join(home, ".ssh/id_ed25519")},
+				UserKnownHostsFiles: []string{"/etc/ssh/known_hosts_db", "/tmp/known hosts"},
+				ConnectTimeout:      15 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSSHConfig(file, tt.alias, tt.localUser)
+			if err != nil {
+				t.Fatalf("ResolveSSHConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveSSHConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProxyJump(t *testing.T) {
+	hops := ParseProxyJump("jump@bastion:2222,gw,[2001:db8::1]:22")
+	want := []*Config{
+		{Host: "bastion", Port: "2222", User: "jump"},
+		{Host: "gw"},
+		{Host: "2001:db8::1", Port: "22"},
+	}
+	if !reflect.DeepEqual(hops, want) {
+		t.Errorf("ParseProxyJump() = %+v, want %+v", hops, want)
+	}
+	if hops := ParseProxyJump("none"); hops != nil {
+		t.Errorf("ParseProxyJump(none) = %+v, want nil", hops)
+	}
+}
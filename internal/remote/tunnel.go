@@ -0,0 +1,151 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Tunnel forwards connections accepted on a local listener to an address
+// reached from the host, the same way as ssh -L. It stays open until Close
+// is called.
+type Tunnel struct {
+	config   *Config
+	remote   string
+	listener net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Tunnel listens on localAddress, 127.0.0.1:0 when empty, and forwards every
+// connection to remoteAddress through the host. The host is connected to
+// once up front so that configuration errors are reported immediately.
+func (c *Config) Tunnel(ctx context.Context, localAddress, remoteAddress string) (*Tunnel, error) {
+	_, release, err := c.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release()
+
+	if localAddress == "" {
+		localAddress = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tunnel{
+		config:   c,
+		remote:   remoteAddress,
+		listener: listener,
+		conns:    map[net.Conn]struct{}{},
+	}
+	go t.serve()
+	return t, nil
+}
+
+// LocalAddress returns the address the tunnel listens on.
+func (t *Tunnel) LocalAddress() net.Addr {
+	return t.listener.Addr()
+}
+
+// Close stops listening, closes the forwarded connections and waits for them
+// to finish.
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	err := t.listener.Close()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mu.Unlock()
+
+	t.wg.Wait()
+	return err
+}
+
+func (t *Tunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			conn.Close()
+			return
+		}
+		t.conns[conn] = struct{}{}
+		t.wg.Add(1)
+		t.mu.Unlock()
+
+		go func() {
+			defer t.wg.Done()
+			t.forward(conn)
+
+			t.mu.Lock()
+			delete(t.conns, conn)
+			t.mu.Unlock()
+		}()
+	}
+}
+
+// forward copies between conn and a channel to the remote address until
+// either side is closed.
+func (t *Tunnel) forward(conn net.Conn) {
+	defer conn.Close()
+
+	remote, release, err := t.dial()
+	if err != nil {
+		return
+	}
+	defer release()
+	defer remote.Close()
+
+	// Closing either side unblocks the other copy.
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(remote, conn)
+		remote.Close()
+		close(done)
+	}()
+	_, _ = io.Copy(conn, remote)
+	conn.Close()
+	<-done
+}
+
+// dial opens a channel to the remote address. A pooled connection may have
+// been dropped since it was last used, so it is replaced once unless the
+// host itself refused the channel.
+func (t *Tunnel) dial() (net.Conn, func(), error) {
+	ctx := context.Background()
+	for attempt := 0; ; attempt++ {
+		client, release, err := t.config.client(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		remote, err := client.Dial("tcp", t.remote)
+		if err == nil {
+			return remote, release, nil
+		}
+		var openErr *ssh.OpenChannelError
+		refused := errors.As(err, &openErr)
+		err = keepaliveError(client, err)
+		release()
+
+		if attempt > 0 || t.config.Pool == nil || refused {
+			return nil, nil, err
+		}
+		t.config.Pool.Discard(client)
+	}
+}
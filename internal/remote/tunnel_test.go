@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConfig_Tunnel(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { echo.Close() })
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	server := newTestServer(t)
+	pool := NewPool(time.Minute)
+	defer pool.Close()
+
+	config := server.config("alice")
+	config.Pool = pool
+
+	tunnel, err := config.Tunnel(context.Background(), "", echo.Addr().String())
+	if err != nil {
+		t.Fatalf("Config.Tunnel() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", tunnel.LocalAddress().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+
+			want := fmt.Sprintf("ping %d\n", i)
+			_, _ = io.WriteString(conn, want)
+			if got, _ := bufio.NewReader(conn).ReadString('\n'); got != want {
+				t.Errorf("tunnelled response = %q, want %q", got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := server.connections(); got != 1 {
+		t.Errorf("connections = %d, want 1", got)
+	}
+
+	// Close must not wait for clients to hang up.
+	open, err := net.Dial("tcp", tunnel.LocalAddress().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+	_, _ = io.WriteString(open, "ping\n")
+	_, _ = bufio.NewReader(open).ReadString('\n')
+
+	if err := tunnel.Close(); err != nil {
+		t.Errorf("Tunnel.Close() error = %v", err)
+	}
+	if _, err := open.Read(make([]byte, 1)); err == nil {
+		t.Error("connection still open after Tunnel.Close()")
+	}
+	if conn, err := net.Dial("tcp", tunnel.LocalAddress().String()); err == nil {
+		conn.Close()
+		t.Error("tunnel still listening after Tunnel.Close()")
+	}
+}
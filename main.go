@@ -24,6 +24,11 @@ func main() {
 		Debug:   debug,
 	})
 
+	// Tunnels opened by data sources live until the plugin is stopped.
+	if err := provider.CloseTunnels(); err != nil {
+		fmt.Printf("error closing tunnels: %s", err)
+	}
+
 	if err != nil {
 		fmt.Printf("error serving provider: %s", err)
 		os.Exit(1)
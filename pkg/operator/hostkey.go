@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// hostKeyCallback accepts host keys matching one of the SHA256 fingerprints
+// or listed in the known_hosts content or files.
+func hostKeyCallback(knownHosts, knownHostsFiles, fingerprints []string) (ssh.HostKeyCallback, error) {
+	pins := make(map[string]bool, len(fingerprints))
+	for _, f := range fingerprints {
+		f = strings.TrimRight(strings.TrimSpace(f), "=")
+		if !strings.HasPrefix(f, "SHA256:") {
+			f = "SHA256:" + f
+		}
+		pins[f] = true
+	}
+
+	files := make([]string, 0, len(knownHostsFiles)+len(knownHosts))
+	for _, f := range knownHostsFiles {
+		if f == "~" || strings.HasPrefix(f, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			f = filepath.Join(home, strings.TrimPrefix(f, "~"))
+		}
+		files = append(files, f)
+	}
+	// knownhosts only reads from files, so inline content is staged in a
+	// temporary file for the duration of the parse.
+	for _, content := range knownHosts {
+		tmp, err := os.CreateTemp("", "known_hosts")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.WriteString(content)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tmp.Name())
+	}
+
+	var known ssh.HostKeyCallback
+	if len(files) > 0 {
+		var err error
+		if known, err = knownhosts.New(files...); err != nil {
+			return nil, fmt.Errorf("unable to load known_hosts: %w", err)
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if pins[fingerprint] {
+			return nil
+		}
+		if cert, ok := key.(*ssh.Certificate); ok && pins[ssh.FingerprintSHA256(cert.Key)] {
+			return nil
+		}
+		if known == nil {
+			return fmt.Errorf("host key verification failed for %s: %s key %s does not match any pinned fingerprint", hostname, key.Type(), fingerprint)
+		}
+		if err := known(hostname, remote, key); err != nil {
+			return fmt.Errorf("host key verification failed for %s: %s key %s: %w", hostname, key.Type(), fingerprint, err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,57 @@
+package operator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestHostKeyCallback(t *testing.T) {
+	key := testPublicKey(t)
+	other := testPublicKey(t)
+	fingerprint := ssh.FingerprintSHA256(key)
+	line := "example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+	tests := []struct {
+		name         string
+		knownHosts   []string
+		fingerprints []string
+		key          ssh.PublicKey
+		wantErr      bool
+	}{
+		{name: "fingerprint", fingerprints: []string{fingerprint}, key: key},
+		{name: "fingerprint without prefix", fingerprints: []string{strings.TrimPrefix(fingerprint, "SHA256:")}, key: key},
+		{name: "fingerprint mismatch", fingerprints: []string{fingerprint}, key: other, wantErr: true},
+		{name: "known hosts", knownHosts: []string{line}, key: key},
+		{name: "known hosts mismatch", knownHosts: []string{line}, key: other, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := hostKeyCallback(tt.knownHosts, nil, tt.fingerprints)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+			if err := callback("example.com:22", addr, tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("hostKeyCallback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func testPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
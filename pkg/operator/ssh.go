@@ -4,6 +4,7 @@ package operator
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -19,6 +20,8 @@ import (
 // SSHOperator executes commands on a remote machine over an SSH session
 type SSHOperator struct {
 	*vssh.VSSH
+
+	hostKeyCallback ssh.HostKeyCallback
 }
 
 func NewSSHOperator() *SSHOperator {
@@ -27,7 +30,33 @@ func NewSSHOperator() *SSHOperator {
 	return operator
 }
 
+// WithHostKeys verifies the host keys of clients added afterwards against
+// known_hosts content, known_hosts files and SHA256 fingerprints. At least
+// one of them must be given.
+func (s *SSHOperator) WithHostKeys(knownHosts, knownHostsFiles, fingerprints []string) (*SSHOperator, error) {
+	if len(knownHosts) == 0 && len(knownHostsFiles) == 0 && len(fingerprints) == 0 {
+		return s, errors.New("no known hosts or fingerprints to verify host keys against")
+	}
+	callback, err := hostKeyCallback(knownHosts, knownHostsFiles, fingerprints)
+	if err != nil {
+		return s, err
+	}
+	s.hostKeyCallback = callback
+	return s, nil
+}
+
+// AddClient connects to address. The host key is verified with the keys
+// given to WithHostKeys, or else with the HostKeyCallback of config; clients
+// with neither are refused rather than trusting any key.
 func (s *SSHOperator) AddClient(address string, config *ssh.ClientConfig) (*SSHOperator, error) {
+	switch {
+	case config == nil:
+		return s, fmt.Errorf("unable to connect to %s: no client config", address)
+	case s.hostKeyCallback != nil:
+		config.HostKeyCallback = s.hostKeyCallback
+	case config.HostKeyCallback == nil:
+		return s, fmt.Errorf("unable to verify the host key of %s: call WithHostKeys or set a HostKeyCallback", address)
+	}
 	if err := s.VSSH.AddClient(address, config); err != nil {
 		return s, err
 	}
@@ -3,12 +3,57 @@ package operator
 
 import (
 	"context"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/yahoo/vssh"
+	"golang.org/x/crypto/ssh"
 )
 
+const testAddress = "192.168.1.198:22"
+
+// testClientConfig returns the client config for the test host, skipping the
+// test when the key or the host is unavailable.
+func testClientConfig(t *testing.T) *ssh.ClientConfig {
+	t.Helper()
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	cfg, err := vssh.GetConfigPEM("root", filepath.Join(home, ".ssh", "id_ed25519"))
+	if err != nil {
+		t.Skipf("no test key: %v", err)
+	}
+	conn, err := net.DialTimeout("tcp", testAddress, 2*time.Second)
+	if err != nil {
+		t.Skipf("test host unavailable: %v", err)
+	}
+	conn.Close()
+	return cfg
+}
+
+func TestSSHOperator_AddClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *ssh.ClientConfig
+	}{
+		{name: "nil config", config: nil},
+		{name: "no host key callback", config: &ssh.ClientConfig{User: "root"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SSHOperator{VSSH: vssh.New()}
+			if _, err := s.AddClient(testAddress, tt.config); err == nil {
+				t.Error("SSHOperator.AddClient() error = nil, want an error")
+			}
+		})
+	}
+}
+
 func TestSSHOperator_CopyFile(t *testing.T) {
 	type fields struct {
 		VSSH *vssh.VSSH
@@ -32,9 +77,9 @@ func TestSSHOperator_CopyFile(t *testing.T) {
 				VSSH: tt.fields.VSSH,
 			}
 			s.Start()
-			cfg, _ := vssh.GetConfigPEM("root", "~/.ssh/id_ed25519")
-			s.AddClient("192.168.1.198", cfg)
-			s.Wait()
+			if _, err := s.AddClient(testAddress, testClientConfig(t)); err != nil {
+				t.Fatal(err)
+			}
 
 			if err := s.CopyFile(tt.args.ctx, tt.args.source, tt.args.dest); (err != nil) != tt.wantErr {
 				t.Errorf("SSHOperator.CopyFile() error = %v, wantErr %v", err, tt.wantErr)
@@ -66,9 +111,9 @@ func TestSSHOperator_ExecuteStdout(t *testing.T) {
 				VSSH: tt.fields.VSSH,
 			}
 			s.Start()
-			cfg, _ := vssh.GetConfigPEM("root", "~/.ssh/id_ed25519")
-			s.AddClient("192.168.1.198", cfg)
-			s.Wait()
+			if _, err := s.AddClient(testAddress, testClientConfig(t)); err != nil {
+				t.Fatal(err)
+			}
 			got, err := s.ExecuteStdout(tt.args.command, tt.args.stream)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SSHOperator.ExecuteStdout() error = %v, wantErr %v", err, tt.wantErr)